@@ -0,0 +1,263 @@
+package streamdeck
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+	"github.com/tardisx/streamdeck-plugin/tools"
+)
+
+// Animation is a sequence of frames, encoded once up front so that playing
+// them costs nothing more than sending.
+type Animation struct {
+	Frames []string        // payloads suitable for events.ESSetImage
+	Delays []time.Duration // per frame delays, if nil FPS is used
+	FPS    float64
+	Loop   bool // start again after the last frame, rather than stopping on it
+}
+
+// NewAnimation encodes frames for playback at fps frames per second. The
+// animation loops.
+func NewAnimation(frames []image.Image, fps float64) (*Animation, error) {
+	a := &Animation{FPS: fps, Loop: true}
+	for _, f := range frames {
+		payload, err := tools.EncodePNG(f, png.BestSpeed)
+		if err != nil {
+			return nil, err
+		}
+		a.Frames = append(a.Frames, payload)
+	}
+	return a, nil
+}
+
+// NewAnimationFromGIF decodes an animated GIF, keeping its frame delays. The
+// animation loops unless the GIF says to play it exactly once.
+func NewAnimationFromGIF(r io.Reader) (*Animation, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.Image) == 0 {
+		return nil, errors.New("gif has no frames")
+	}
+
+	a := &Animation{Loop: g.LoopCount != -1}
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		bounds = g.Image[0].Bounds()
+	}
+	canvas := image.NewRGBA(bounds)
+	for i, frame := range g.Image {
+		disposal := byte(gif.DisposalNone)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			draw.Draw(previous, bounds, canvas, bounds.Min, draw.Src)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		payload, err := tools.EncodePNG(canvas, png.BestSpeed)
+		if err != nil {
+			return nil, err
+		}
+		a.Frames = append(a.Frames, payload)
+
+		// browsers treat a zero delay as 100ms, so shall we
+		delay := 100 * time.Millisecond
+		if i < len(g.Delay) && g.Delay[i] > 0 {
+			delay = time.Duration(g.Delay[i]) * 10 * time.Millisecond
+		}
+		a.Delays = append(a.Delays, delay)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return a, nil
+}
+
+// delay returns how long frame i should be shown for.
+func (a *Animation) delay(i int) time.Duration {
+	if i < len(a.Delays) {
+		return a.Delays[i]
+	}
+	if a.FPS <= 0 {
+		return time.Second
+	}
+	return time.Duration(float64(time.Second) / a.FPS)
+}
+
+// Animator plays animations on contexts. A context plays one animation at a
+// time, and stops automatically when it disappears. Frame timers do not run
+// while the system is asleep, so playback pauses then. When the Stream Deck
+// application reports that the system has woken up, every animation moves on
+// to its next frame at once rather than finishing the delay it was in.
+type Animator struct {
+	conn *Connection
+
+	mu       sync.Mutex
+	playing  map[string]chan bool // closed to stop
+	minGap   time.Duration        // between any two frames, for all contexts
+	next     time.Time            // when the next frame may be sent
+	wakeup   chan bool            // closed on wake
+	finished sync.WaitGroup
+}
+
+// NewAnimator creates an Animator which sends frames over conn, no more than
+// maxFPS frames per second across all contexts combined. A maxFPS of zero
+// means there is no ceiling.
+func NewAnimator(conn *Connection, maxFPS float64) *Animator {
+	a := &Animator{
+		conn:    conn,
+		playing: make(map[string]chan bool),
+		wakeup:  make(chan bool),
+	}
+	if maxFPS > 0 {
+		a.minGap = time.Duration(float64(time.Second) / maxFPS)
+	}
+	conn.observe(func(event any) {
+		switch e := event.(type) {
+		case events.ERWillDisappear:
+			a.Stop(e.Context)
+		case events.ERApplicationSystemDidWakeUp:
+			a.wake()
+		}
+	})
+	return a
+}
+
+// Play starts playing anim on a context, replacing anything already playing
+// there.
+func (a *Animator) Play(context string, anim *Animation) {
+	if len(anim.Frames) == 0 {
+		return
+	}
+	stop := make(chan bool)
+
+	a.mu.Lock()
+	if prev, ok := a.playing[context]; ok {
+		close(prev)
+	}
+	a.playing[context] = stop
+	a.mu.Unlock()
+
+	a.finished.Add(1)
+	go a.run(context, anim, stop)
+}
+
+// Stop stops any animation playing on a context. The last frame shown stays
+// on the key.
+func (a *Animator) Stop(context string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if stop, ok := a.playing[context]; ok {
+		close(stop)
+		delete(a.playing, context)
+	}
+}
+
+// StopAll stops every animation and waits for them to finish.
+func (a *Animator) StopAll() {
+	a.mu.Lock()
+	for context, stop := range a.playing {
+		close(stop)
+		delete(a.playing, context)
+	}
+	a.mu.Unlock()
+	a.finished.Wait()
+}
+
+// Playing returns true if an animation is playing on a context.
+func (a *Animator) Playing(context string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, ok := a.playing[context]
+	return ok
+}
+
+func (a *Animator) run(context string, anim *Animation, stop chan bool) {
+	defer a.finished.Done()
+
+	for i := 0; ; i++ {
+		if i == len(anim.Frames) {
+			if !anim.Loop {
+				a.mu.Lock()
+				if a.playing[context] == stop {
+					delete(a.playing, context)
+				}
+				a.mu.Unlock()
+				return
+			}
+			i = 0
+		}
+
+		if !a.waitTurn(stop) {
+			return
+		}
+		err := a.conn.Send(events.NewESSetImage(context, anim.Frames[i], events.EventTargetBoth, nil))
+		if err != nil {
+			a.conn.logger.Error("cannot send animation frame: " + err.Error())
+		}
+
+		a.mu.Lock()
+		wakeup := a.wakeup
+		a.mu.Unlock()
+		t := time.NewTimer(anim.delay(i))
+		select {
+		case <-stop:
+			t.Stop()
+			return
+		case <-wakeup:
+			t.Stop()
+		case <-t.C:
+		}
+	}
+}
+
+// waitTurn blocks until the global rate ceiling allows another frame. It
+// returns false if stop was closed while waiting.
+func (a *Animator) waitTurn(stop chan bool) bool {
+	a.mu.Lock()
+	now := time.Now()
+	wait := a.next.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	a.next = now.Add(wait + a.minGap)
+	a.mu.Unlock()
+
+	if wait == 0 {
+		return true
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-stop:
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// wake moves every animation on to its next frame.
+func (a *Animator) wake() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	close(a.wakeup)
+	a.wakeup = make(chan bool)
+	// anything held back by the ceiling before sleeping can go now
+	a.next = time.Time{}
+}
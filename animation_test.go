@@ -0,0 +1,107 @@
+package streamdeck
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+func TestAnimator(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	a := NewAnimator(&c, 0)
+
+	frames := []image.Image{}
+	for _, col := range []color.Color{color.White, color.Black} {
+		img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+		img.Set(0, 0, col)
+		frames = append(frames, img)
+	}
+	anim, err := NewAnimation(frames, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.Play("ABC123", anim)
+	for i := 0; i < 4; i++ {
+		b, err := hostEnd.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := events.ESSetImage{}
+		json.Unmarshal(b, &e)
+		if e.Payload.Image != anim.Frames[i%2] {
+			t.Errorf("frame %d out of order", i)
+		}
+	}
+
+	c.handle(events.ERWillDisappear{ERCommon: events.ERCommon{Context: "ABC123"}})
+	if a.Playing("ABC123") {
+		t.Error("animation still playing after willDisappear")
+	}
+	a.StopAll()
+
+	// drain anything sent before the stop took effect
+	pluginEnd.Close()
+	for {
+		if _, err := hostEnd.ReadFrame(); err != nil {
+			break
+		}
+	}
+}
+
+func TestAnimatorCeiling(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	a := NewAnimator(&c, 20)
+
+	anim := &Animation{Frames: []string{"a", "b"}, FPS: 1000, Loop: true}
+	a.Play("ABC123", anim)
+	a.Play("DEF456", anim)
+
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		_, err := hostEnd.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 6 frames at 20 per second can't take less than 250ms
+	if elapsed := time.Since(start); elapsed < 240*time.Millisecond {
+		t.Errorf("frames sent too quickly: %s", elapsed)
+	}
+	a.StopAll()
+}
+
+func TestAnimatorWake(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	a := NewAnimator(&c, 0)
+
+	anim := &Animation{Frames: []string{"a", "b"}, Delays: []time.Duration{time.Hour, time.Hour}, Loop: true}
+	a.Play("ABC123", anim)
+	if _, err := hostEnd.ReadFrame(); err != nil {
+		t.Fatal(err)
+	}
+
+	// waking, however often and whenever it comes, moves straight on
+	for _, want := range []string{"b", "a"} {
+		// give the animation time to start waiting on the frame
+		time.Sleep(50 * time.Millisecond)
+		c.handle(events.ERApplicationSystemDidWakeUp{})
+		b, err := hostEnd.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := events.ESSetImage{}
+		json.Unmarshal(b, &e)
+		if e.Payload.Image != want {
+			t.Errorf("expected frame %s after waking, got %s", want, e.Payload.Image)
+		}
+	}
+	a.StopAll()
+}
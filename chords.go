@@ -0,0 +1,234 @@
+package streamdeck
+
+import (
+	"errors"
+	"image"
+	"sync"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+// ChordKey picks the keys which can take part in a chord, by the UUID of
+// their action, by their position, or by both.
+type ChordKey struct {
+	Action string
+	// Coordinates is the column and row of the key, nil for any position.
+	Coordinates *image.Point
+}
+
+func (k ChordKey) matches(action string, pos image.Point) bool {
+	if k.Action != "" && k.Action != action {
+		return false
+	}
+	return k.Coordinates == nil || *k.Coordinates == pos
+}
+
+// Chord is a combination of keys held down together on one device.
+type Chord struct {
+	Name string
+	Keys []ChordKey
+}
+
+// Chords detects chords, such as holding one key and pressing another, and
+// sends an events.KeyChord when every key of a chord is held at once. The
+// keyDown and keyUp events of the keys in the chord are not passed on to
+// observers or handlers.
+//
+// As it cannot be known whether a key will become part of a chord until
+// another key is pressed, the keyDown of any key which could be part of a
+// chord is held back. If the key is released without completing a chord its
+// keyDown is passed on immediately before its keyUp. Other keys, and keys in
+// multi-actions, are not affected.
+type Chords struct {
+	conn *Connection
+
+	mu      sync.Mutex
+	chords  []Chord
+	devices map[string][]*heldKey // in the order they were pressed
+}
+
+type heldKey struct {
+	info events.HeldKey
+	// down is the keyDown held back until it is known whether the key is
+	// part of a chord, nil once it is.
+	down    *events.ERKeyDown
+	chorded bool
+}
+
+// NewChords creates a Chords. It should be created before Connect. Key events
+// it holds back are not seen by other helpers either, such as KeyGestures.
+func NewChords(conn *Connection) *Chords {
+	c := &Chords{
+		conn:    conn,
+		devices: make(map[string][]*heldKey),
+	}
+	conn.intercept(c.intercept)
+	return c
+}
+
+// Add declares a chord, which must have at least two keys.
+func (c *Chords) Add(chord Chord) error {
+	if len(chord.Keys) < 2 {
+		return errors.New("a chord needs at least two keys")
+	}
+	c.mu.Lock()
+	c.chords = append(c.chords, chord)
+	c.mu.Unlock()
+	return nil
+}
+
+// Held returns the keys which could be part of a chord that are held down on
+// a device, in the order they were pressed.
+func (c *Chords) Held(device string) []events.HeldKey {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	held := []events.HeldKey{}
+	for _, h := range c.devices[device] {
+		held = append(held, h.info)
+	}
+	return held
+}
+
+// intercept reports whether an event is swallowed.
+func (c *Chords) intercept(event any) bool {
+	switch e := event.(type) {
+	case events.ERKeyDown:
+		if e.Payload.IsInMultiAction {
+			return false
+		}
+		return c.keyDown(e)
+	case events.ERKeyUp:
+		if e.Payload.IsInMultiAction {
+			return false
+		}
+		return c.keyUp(e.Device, e.Context)
+	case events.ERWillDisappear:
+		c.mu.Lock()
+		c.remove(e.Device, e.Context)
+		c.mu.Unlock()
+	case events.ERDeviceDidDisconnect:
+		c.mu.Lock()
+		delete(c.devices, e.Device)
+		c.mu.Unlock()
+	}
+	return false
+}
+
+func (c *Chords) keyDown(e events.ERKeyDown) bool {
+	pos := image.Pt(e.Payload.Coordinates.Column, e.Payload.Coordinates.Row)
+	c.mu.Lock()
+	if !c.candidate(e.Action, pos) {
+		c.mu.Unlock()
+		return false
+	}
+
+	// a repeated keyDown replaces the first
+	c.remove(e.Device, e.Context)
+	h := &heldKey{down: &e}
+	h.info.Action = e.Action
+	h.info.Context = e.Context
+	h.info.Coordinates = e.Payload.Coordinates
+	held := append(c.devices[e.Device], h)
+	c.devices[e.Device] = held
+
+	var chord *events.KeyChord
+	for _, ch := range c.chords {
+		keys, ok := match(ch.Keys, held, h)
+		if !ok {
+			continue
+		}
+		chord = &events.KeyChord{ERCommon: withEvent(e.ERCommon, "keyChord"), Chord: ch.Name}
+		for _, k := range keys {
+			k.chorded = true
+			k.down = nil
+			chord.Keys = append(chord.Keys, k.info)
+		}
+		break
+	}
+	c.mu.Unlock()
+
+	if chord != nil {
+		c.conn.deliver(*chord)
+	}
+	return true
+}
+
+func (c *Chords) keyUp(device, context string) bool {
+	c.mu.Lock()
+	h := c.remove(device, context)
+	c.mu.Unlock()
+	if h == nil {
+		return false
+	}
+	if h.chorded {
+		return true
+	}
+	// not part of a chord after all, pass on the press before the release
+	if h.down != nil {
+		c.conn.deliver(*h.down)
+	}
+	return false
+}
+
+// candidate reports whether a key could be part of any chord. The caller must
+// hold the lock.
+func (c *Chords) candidate(action string, pos image.Point) bool {
+	for _, ch := range c.chords {
+		for _, k := range ch.Keys {
+			if k.matches(action, pos) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// remove forgets a held key, returning it if it was held. The caller must
+// hold the lock.
+func (c *Chords) remove(device, context string) *heldKey {
+	held := c.devices[device]
+	for i, h := range held {
+		if h.info.Context == context {
+			c.devices[device] = append(held[:i:i], held[i+1:]...)
+			return h
+		}
+	}
+	return nil
+}
+
+// match finds a different held key for each of keys, including the key just
+// pressed, and returns them in the order they were pressed.
+func match(keys []ChordKey, held []*heldKey, pressed *heldKey) ([]*heldKey, bool) {
+	if len(keys) > len(held) {
+		return nil, false
+	}
+	used := make([]bool, len(held))
+	var assign func(i int) bool
+	assign = func(i int) bool {
+		if i == len(keys) {
+			return used[len(held)-1] // the key just pressed is last
+		}
+		for j, h := range held {
+			pos := image.Pt(h.info.Coordinates.Column, h.info.Coordinates.Row)
+			if used[j] || !keys[i].matches(h.info.Action, pos) {
+				continue
+			}
+			used[j] = true
+			if assign(i + 1) {
+				return true
+			}
+			used[j] = false
+		}
+		return false
+	}
+	if held[len(held)-1] != pressed || !assign(0) {
+		return nil, false
+	}
+	matched := []*heldKey{}
+	for j, h := range held {
+		if used[j] {
+			matched = append(matched, h)
+		}
+	}
+	return matched, true
+}
@@ -0,0 +1,85 @@
+package streamdeck
+
+import (
+	"image"
+	"testing"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+func chordKey(down bool, action, context string, col, row int) any {
+	common := events.ERCommon{Action: action, Context: context, Device: "DEV1"}
+	if down {
+		e := events.ERKeyDown{ERCommon: common}
+		e.Payload.Coordinates.Column, e.Payload.Coordinates.Row = col, row
+		return e
+	}
+	e := events.ERKeyUp{ERCommon: common}
+	e.Payload.Coordinates.Column, e.Payload.Coordinates.Row = col, row
+	return e
+}
+
+func TestChords(t *testing.T) {
+	c := New()
+	chords := NewChords(&c)
+	err := chords.Add(Chord{Name: "shift-record", Keys: []ChordKey{
+		{Action: "com.example.shift"},
+		{Action: "com.example.record"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	corner := image.Pt(4, 2)
+	chords.Add(Chord{Name: "corner", Keys: []ChordKey{{Action: "com.example.shift"}, {Coordinates: &corner}}})
+	if chords.Add(Chord{Name: "single", Keys: []ChordKey{{Action: "x"}}}) == nil {
+		t.Error("expected a single key chord to be refused")
+	}
+
+	got := []string{}
+	c.RegisterHandler(func(e events.ERKeyDown) { got = append(got, "down "+e.Context) })
+	c.RegisterHandler(func(e events.ERKeyUp) { got = append(got, "up "+e.Context) })
+	c.RegisterHandler(func(e events.KeyChord) {
+		s := "chord " + e.Chord
+		for _, k := range e.Keys {
+			s += " " + k.Context
+		}
+		got = append(got, s)
+	})
+
+	// hold shift, press record twice, release shift
+	c.handle(chordKey(true, "com.example.shift", "S", 0, 0))
+	c.handle(chordKey(true, "com.example.record", "R", 1, 0))
+	c.handle(chordKey(false, "com.example.record", "R", 1, 0))
+	c.handle(chordKey(true, "com.example.record", "R", 1, 0))
+	c.handle(chordKey(false, "com.example.record", "R", 1, 0))
+	c.handle(chordKey(false, "com.example.shift", "S", 0, 0))
+	// shift alone is passed on when released
+	c.handle(chordKey(true, "com.example.shift", "S", 0, 0))
+	c.handle(chordKey(true, "com.example.other", "O", 2, 0))
+	c.handle(chordKey(false, "com.example.other", "O", 2, 0))
+	c.handle(chordKey(false, "com.example.shift", "S", 0, 0))
+	// by position
+	c.handle(chordKey(true, "com.example.other", "X", 4, 2))
+	c.handle(chordKey(true, "com.example.shift", "S", 0, 0))
+	c.handle(chordKey(false, "com.example.shift", "S", 0, 0))
+	c.handle(chordKey(false, "com.example.other", "X", 4, 2))
+
+	expected := []string{
+		"chord shift-record S R",
+		"chord shift-record S R",
+		"down O", "up O",
+		"down S", "up S",
+		"chord corner X S",
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], got[i])
+		}
+	}
+	if held := chords.Held("DEV1"); len(held) != 0 {
+		t.Errorf("expected nothing held, got %v", held)
+	}
+}
@@ -0,0 +1,225 @@
+package streamdeck
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+// SetCoalescing enables coalescing of setTitle, setImage and setFeedback
+// messages. Each (context, event, state) combination is sent at most once
+// per interval. An update arriving sooner is held back, and replaced by any
+// later update for the same combination, so only the latest one is sent when
+// the interval has passed. Partial setFeedback payloads are merged rather
+// than replaced. A setFeedbackLayout sends any setFeedback held back for the
+// context first, so that it is not applied to the new layout.
+//
+// All other messages, such as showOk, openUrl and setSettings, are sent
+// immediately and in order. Passing an interval of zero disables coalescing,
+// sending anything still held back.
+func (conn *Connection) SetCoalescing(interval time.Duration) {
+	if conn.coalescer != nil {
+		conn.coalescer.stop()
+		conn.coalescer = nil
+	}
+	if interval > 0 {
+		conn.coalescer = newCoalescer(conn, interval)
+	}
+}
+
+type coalesceKey struct {
+	context string
+	event   string
+	state   int
+}
+
+// noState is used in keys for events without a state.
+const noState = -1
+
+// pruneThreshold is the number of remembered keys above which old ones are
+// forgotten.
+const pruneThreshold = 256
+
+type coalescer struct {
+	conn     *Connection
+	interval time.Duration
+
+	mu       sync.Mutex
+	lastSent map[coalesceKey]time.Time
+	pending  map[coalesceKey]any
+	timers   map[coalesceKey]*time.Timer
+
+	// flushing is held while a pending update is taken and sent, so that
+	// waiting for it also waits for a send already under way.
+	flushing sync.Mutex
+}
+
+func newCoalescer(conn *Connection, interval time.Duration) *coalescer {
+	return &coalescer{
+		conn:     conn,
+		interval: interval,
+		lastSent: make(map[coalesceKey]time.Time),
+		pending:  make(map[coalesceKey]any),
+		timers:   make(map[coalesceKey]*time.Timer),
+	}
+}
+
+// coalesceKeyFor returns the key for messages which can be coalesced.
+func coalesceKeyFor(e any) (coalesceKey, bool) {
+	switch e := e.(type) {
+	case events.ESSetTitle:
+		return coalesceKey{context: e.Context, event: e.Event, state: e.Payload.State}, true
+	case events.ESSetImage:
+		state := noState
+		if e.Payload.State != nil {
+			state = *e.Payload.State
+		}
+		return coalesceKey{context: e.Context, event: e.Event, state: state}, true
+	case events.ESSetFeedback:
+		return coalesceKey{context: e.Context, event: e.Event, state: noState}, true
+	}
+	return coalesceKey{}, false
+}
+
+// offer takes a message about to be sent. If it can be coalesced it is
+// either sent now or held back, and offer returns true.
+func (c *coalescer) offer(e any) (bool, error) {
+	if l, ok := e.(events.ESSetFeedbackLayout); ok {
+		// feedback held back was meant for the old layout, so it must
+		// arrive before the layout changes
+		c.flushNow(coalesceKey{context: l.Context, event: "setFeedback", state: noState})
+		return false, nil
+	}
+	key, ok := coalesceKeyFor(e)
+	if !ok {
+		return false, nil
+	}
+
+	c.mu.Lock()
+	if prev, ok := c.pending[key]; ok {
+		c.pending[key] = mergeUpdate(prev, e)
+		c.mu.Unlock()
+		return true, nil
+	}
+
+	now := time.Now()
+	wait := c.interval - now.Sub(c.lastSent[key])
+	if wait <= 0 {
+		c.lastSent[key] = now
+		c.prune(now)
+		c.mu.Unlock()
+		return true, c.send(e)
+	}
+
+	c.pending[key] = e
+	c.timers[key] = time.AfterFunc(wait, func() { c.flush(key) })
+	c.mu.Unlock()
+	return true, nil
+}
+
+// flush sends the pending update for key, if there still is one. If its
+// timer is sending it at the same time, flush waits for that to finish.
+func (c *coalescer) flush(key coalesceKey) {
+	c.flushing.Lock()
+	defer c.flushing.Unlock()
+
+	c.mu.Lock()
+	e, ok := c.pending[key]
+	delete(c.pending, key)
+	delete(c.timers, key)
+	if ok {
+		c.lastSent[key] = time.Now()
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	err := c.send(e)
+	if err != nil {
+		c.conn.logger.Error("cannot send coalesced update: " + err.Error())
+	}
+}
+
+// flushNow sends the pending update for key immediately, rather than waiting
+// for its timer. When it returns, any update for key has been sent.
+func (c *coalescer) flushNow(key coalesceKey) {
+	c.mu.Lock()
+	if t, ok := c.timers[key]; ok {
+		t.Stop()
+	}
+	c.mu.Unlock()
+	c.flush(key)
+}
+
+// stop cancels all timers and sends anything pending immediately, waiting
+// for any sends already under way.
+func (c *coalescer) stop() {
+	c.mu.Lock()
+	keys := make([]coalesceKey, 0, len(c.timers))
+	for key, t := range c.timers {
+		t.Stop()
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+
+	for _, key := range keys {
+		c.flush(key)
+	}
+	// wait for a timer which took its update before we looked
+	c.flushing.Lock()
+	c.flushing.Unlock()
+}
+
+// prune forgets keys which were last sent more than an interval ago, so
+// that contexts which have gone away do not accumulate. The caller must hold
+// the lock.
+func (c *coalescer) prune(now time.Time) {
+	if len(c.lastSent) < pruneThreshold {
+		return
+	}
+	for key, t := range c.lastSent {
+		if now.Sub(t) > c.interval {
+			delete(c.lastSent, key)
+		}
+	}
+}
+
+func (c *coalescer) send(e any) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return c.conn.write(b)
+}
+
+// mergeUpdate combines a pending update with a newer one. setFeedback
+// payloads are partial, so their keys are merged, everything else is
+// simply replaced.
+func mergeUpdate(prev any, next any) any {
+	p, ok := prev.(events.ESSetFeedback)
+	if !ok {
+		return next
+	}
+	n := next.(events.ESSetFeedback)
+
+	merged := map[string]json.RawMessage{}
+	if json.Unmarshal(p.Payload, &merged) != nil {
+		return next
+	}
+	update := map[string]json.RawMessage{}
+	if json.Unmarshal(n.Payload, &update) != nil {
+		return next
+	}
+	for k, v := range update {
+		merged[k] = v
+	}
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return next
+	}
+	n.Payload = b
+	return n
+}
@@ -0,0 +1,126 @@
+package streamdeck
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+func TestCoalescing(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	c.SetCoalescing(50 * time.Millisecond)
+
+	c.Send(events.NewESSetTitle("ABC123", "1", events.EventTargetBoth, 0))
+	c.Send(events.NewESSetTitle("ABC123", "2", events.EventTargetBoth, 0))
+	c.Send(events.NewESShowOK("ABC123"))
+	c.Send(events.NewESSetTitle("ABC123", "3", events.EventTargetBoth, 0))
+	c.Send(events.NewESSetFeedback("ABC123", json.RawMessage(`{"title":"a"}`)))
+	c.Send(events.NewESSetFeedback("ABC123", json.RawMessage(`{"value":"b"}`)))
+	c.Send(events.NewESSetFeedback("ABC123", json.RawMessage(`{"value":"c"}`)))
+
+	expected := []string{
+		`{"event":"setTitle","context":"ABC123","payload":{"title":"1","target":0,"state":0}}`,
+		`{"event":"showOk","context":"ABC123"}`,
+		`{"event":"setFeedback","context":"ABC123","payload":{"title":"a"}}`,
+	}
+	// the remainder arrive after the interval, in either order
+	later := map[string]bool{
+		`{"event":"setTitle","context":"ABC123","payload":{"title":"3","target":0,"state":0}}`: true,
+		`{"event":"setFeedback","context":"ABC123","payload":{"value":"c"}}`:                   true,
+	}
+
+	for _, e := range expected {
+		b, err := hostEnd.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != e {
+			t.Errorf("expected %s, got %s", e, b)
+		}
+	}
+	for n := len(later); n > 0; n-- {
+		b, err := hostEnd.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !later[string(b)] {
+			t.Errorf("unexpected %s", b)
+		}
+		delete(later, string(b))
+	}
+
+	c.SetCoalescing(0)
+	c.Send(events.NewESSetTitle("ABC123", "4", events.EventTargetBoth, 0))
+	b, _ := hostEnd.ReadFrame()
+	if string(b) != `{"event":"setTitle","context":"ABC123","payload":{"title":"4","target":0,"state":0}}` {
+		t.Errorf("coalescing was not disabled, got %s", b)
+	}
+}
+
+func TestCoalescingLayoutChange(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	c.SetCoalescing(time.Hour)
+
+	c.Send(events.NewESSetFeedback("ABC123", json.RawMessage(`{"title":"a"}`)))
+	c.Send(events.NewESSetFeedback("ABC123", json.RawMessage(`{"title":"b"}`)))
+	c.Send(events.NewESSetFeedbackLayout("ABC123", "$B1"))
+	c.Send(events.NewESSetFeedback("ABC123", json.RawMessage(`{"value":"c"}`)))
+	pluginEnd.Close()
+
+	expected := []string{
+		`{"event":"setFeedback","context":"ABC123","payload":{"title":"a"}}`,
+		`{"event":"setFeedback","context":"ABC123","payload":{"title":"b"}}`,
+		`{"event":"setFeedbackLayout","context":"ABC123","payload":{"layout":"$B1"}}`,
+	}
+	for _, e := range expected {
+		b, err := hostEnd.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != e {
+			t.Errorf("expected %s, got %s", e, b)
+		}
+	}
+}
+
+// slowTransport delays writing frames containing slow, as if the connection
+// were busy.
+type slowTransport struct {
+	Transport
+	slow    string
+	writing chan bool
+}
+
+func (s slowTransport) WriteFrame(b []byte) error {
+	if strings.Contains(string(b), s.slow) {
+		s.writing <- true
+		time.Sleep(50 * time.Millisecond)
+	}
+	return s.Transport.WriteFrame(b)
+}
+
+func TestCoalescingLayoutChangeInFlight(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	writing := make(chan bool, 1)
+	c := NewWithTransport(slowTransport{Transport: pluginEnd, slow: `"title":"b"`, writing: writing})
+	c.SetCoalescing(10 * time.Millisecond)
+
+	c.Send(events.NewESSetFeedback("ABC123", json.RawMessage(`{"title":"a"}`)))
+	c.Send(events.NewESSetFeedback("ABC123", json.RawMessage(`{"title":"b"}`)))
+	// the held back update is being sent by its timer
+	<-writing
+	c.Send(events.NewESSetFeedbackLayout("ABC123", "$B1"))
+	c.SetCoalescing(0)
+	pluginEnd.Close()
+
+	checkFrames(t, sentFrames(t, hostEnd), []string{
+		`{"event":"setFeedback","context":"ABC123","payload":{"title":"a"}}`,
+		`{"event":"setFeedback","context":"ABC123","payload":{"title":"b"}}`,
+		`{"event":"setFeedbackLayout","context":"ABC123","payload":{"layout":"$B1"}}`,
+	})
+}
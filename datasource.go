@@ -0,0 +1,476 @@
+package streamdeck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+	"github.com/tardisx/streamdeck-plugin/layout"
+	"github.com/tardisx/streamdeck-plugin/tools"
+)
+
+// FetchFunc fetches the data of a data source, such as the response of a
+// backend call. The context is cancelled when no key bound to the source is
+// shown any more.
+type FetchFunc func(ctx context.Context) (any, error)
+
+// DataSourceOptions controls how a data source is polled.
+type DataSourceOptions struct {
+	// Interval is the time between fetches, defaults to 10 seconds.
+	Interval time.Duration
+	// MaxBackoff limits how far the time between fetches grows while they
+	// are failing. It doubles with every failure, starting from Interval.
+	// Defaults to 5 minutes, or Interval if that is longer.
+	MaxBackoff time.Duration
+	// StaleAfter is how long after the last successful fetch the data is
+	// considered stale. Defaults to three times Interval.
+	StaleAfter time.Duration
+}
+
+func (o DataSourceOptions) withDefaults() DataSourceOptions {
+	if o.Interval <= 0 {
+		o.Interval = 10 * time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = max(5*time.Minute, o.Interval)
+	}
+	if o.StaleAfter <= 0 {
+		o.StaleAfter = 3 * o.Interval
+	}
+	return o
+}
+
+// Binding shows part of the data of a source on every instance of an action.
+// At least one of Title, Image and Feedback must be set. Each is passed the
+// value picked by Derive, and whether the data is stale, and is only called
+// when either of them has changed since the key was last updated.
+type Binding struct {
+	Source string
+	// Derive picks the part of the data a key shows, using the key's
+	// settings if needed. Values are compared with reflect.DeepEqual. If
+	// nil, the whole of the data is used.
+	Derive func(e events.ERWillAppear, data any) any
+
+	Title    func(v any, stale bool) string
+	Image    func(v any, stale bool) (image.Image, error)
+	Feedback func(v any, stale bool) layout.Feedback
+}
+
+// Bindings polls named data sources and shows values derived from them on
+// keys, so that several keys showing different fields of the same backend
+// call share a single request.
+//
+// A source is only polled while at least one key bound to it is shown, and
+// keys in multi-actions are not bound.
+type Bindings struct {
+	conn *Connection
+
+	mu       sync.Mutex
+	sources  map[string]*DataSource
+	bindings map[string][]*Binding           // by action
+	keys     map[string]*boundKey            // by context
+	shown    map[*DataSource]map[string]bool // contexts bound to each source
+}
+
+// boundKey is a key being shown, whether or not its action has bindings.
+type boundKey struct {
+	appear events.ERWillAppear
+	// last holds the value and staleness each binding last showed.
+	last map[*Binding]shownValue
+}
+
+type shownValue struct {
+	v     any
+	stale bool
+}
+
+// DataSource is a named source of data, fetched periodically.
+type DataSource struct {
+	name  string
+	fetch FetchFunc
+	opts  DataSourceOptions
+
+	mu       sync.Mutex
+	data     any
+	fetched  time.Time // of the last successful fetch
+	err      error     // of the last fetch
+	cancel   context.CancelFunc
+	refresh  chan struct{}
+	goStale  *time.Timer // updates the keys when the data goes stale
+	updateMu sync.Mutex  // keeps key updates in order
+}
+
+// NewBindings creates a Bindings. It should be created before Connect, so
+// that it sees every willAppear.
+func NewBindings(conn *Connection) *Bindings {
+	b := &Bindings{
+		conn:     conn,
+		sources:  make(map[string]*DataSource),
+		bindings: make(map[string][]*Binding),
+		keys:     make(map[string]*boundKey),
+		shown:    make(map[*DataSource]map[string]bool),
+	}
+	conn.observe(func(event any) {
+		switch e := event.(type) {
+		case events.ERWillAppear:
+			if !e.Payload.IsInMultiAction {
+				b.appear(e)
+			}
+		case events.ERWillDisappear:
+			b.disappear(e.Context)
+		case events.ERDidReceiveSettings:
+			b.settings(e.Context, e.Payload.Settings)
+		}
+	})
+	return b
+}
+
+// AddSource adds a data source, which must have a unique name.
+func (b *Bindings) AddSource(name string, fetch FetchFunc, opts DataSourceOptions) (*DataSource, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.sources[name]; ok {
+		return nil, fmt.Errorf("data source %s already exists", name)
+	}
+	src := &DataSource{
+		name:    name,
+		fetch:   fetch,
+		opts:    opts.withDefaults(),
+		refresh: make(chan struct{}, 1),
+	}
+	b.sources[name] = src
+	return src, nil
+}
+
+// Source returns the data source with the given name.
+func (b *Bindings) Source(name string) (*DataSource, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	src, ok := b.sources[name]
+	return src, ok
+}
+
+// Bind shows part of a source's data on every instance of the action with
+// the given UUID, including any already shown.
+func (b *Bindings) Bind(action string, binding Binding) error {
+	if binding.Title == nil && binding.Image == nil && binding.Feedback == nil {
+		return errors.New("a binding needs a title, image or feedback")
+	}
+	b.mu.Lock()
+	src, ok := b.sources[binding.Source]
+	if !ok {
+		b.mu.Unlock()
+		return fmt.Errorf("no data source %s", binding.Source)
+	}
+	b.bindings[action] = append(b.bindings[action], &binding)
+	contexts := []string{}
+	for context, key := range b.keys {
+		if key.appear.Action == action {
+			b.subscribe(src, context)
+			contexts = append(contexts, context)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, context := range contexts {
+		b.update(src, context)
+	}
+	return nil
+}
+
+func (b *Bindings) appear(e events.ERWillAppear) {
+	b.mu.Lock()
+	b.remove(e.Context)
+	bindings := b.bindings[e.Action]
+	// keys of actions without bindings yet are tracked, in case they are
+	// bound later
+	b.keys[e.Context] = &boundKey{appear: e, last: make(map[*Binding]shownValue)}
+	sources := []*DataSource{}
+	for _, binding := range bindings {
+		src := b.sources[binding.Source]
+		b.subscribe(src, e.Context)
+		sources = append(sources, src)
+	}
+	b.mu.Unlock()
+
+	// show the data already fetched, if any
+	for _, src := range sources {
+		b.update(src, e.Context)
+	}
+}
+
+func (b *Bindings) disappear(context string) {
+	b.mu.Lock()
+	b.remove(context)
+	b.mu.Unlock()
+}
+
+// subscribe shows a source on a key, and starts polling it if it was not
+// shown before. The caller must hold the lock.
+func (b *Bindings) subscribe(src *DataSource, context string) {
+	if b.shown[src] == nil {
+		b.shown[src] = make(map[string]bool)
+	}
+	if len(b.shown[src]) == 0 {
+		src.start(b)
+	}
+	b.shown[src][context] = true
+}
+
+// remove forgets a key, and stops polling sources no longer shown. The
+// caller must hold the lock.
+func (b *Bindings) remove(context string) {
+	if _, ok := b.keys[context]; !ok {
+		return
+	}
+	delete(b.keys, context)
+	for src, contexts := range b.shown {
+		if !contexts[context] {
+			continue
+		}
+		delete(contexts, context)
+		if len(contexts) == 0 {
+			src.stop()
+		}
+	}
+}
+
+// settings re-derives the values shown on a key when its settings change.
+func (b *Bindings) settings(context string, settings json.RawMessage) {
+	b.mu.Lock()
+	key, ok := b.keys[context]
+	if !ok {
+		b.mu.Unlock()
+		return
+	}
+	key.appear.Payload.Settings = settings
+	sources := []*DataSource{}
+	for src, contexts := range b.shown {
+		if contexts[context] {
+			sources = append(sources, src)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, src := range sources {
+		b.update(src, context)
+	}
+}
+
+// updateAll updates every key bound to a source.
+func (b *Bindings) updateAll(src *DataSource) {
+	b.mu.Lock()
+	contexts := []string{}
+	for context := range b.shown[src] {
+		contexts = append(contexts, context)
+	}
+	b.mu.Unlock()
+
+	for _, context := range contexts {
+		b.update(src, context)
+	}
+}
+
+// update shows a source's data on a key, for each binding whose value has
+// changed.
+func (b *Bindings) update(src *DataSource, context string) {
+	src.updateMu.Lock()
+	defer src.updateMu.Unlock()
+
+	data, fetched, _ := src.Get()
+	if fetched.IsZero() {
+		return
+	}
+	stale := src.Stale()
+
+	b.mu.Lock()
+	key, ok := b.keys[context]
+	if !ok {
+		b.mu.Unlock()
+		return
+	}
+	e := key.appear
+	bindings := b.bindings[e.Action]
+	b.mu.Unlock()
+
+	for _, binding := range bindings {
+		if binding.Source != src.name {
+			continue
+		}
+		v := data
+		if binding.Derive != nil {
+			v = binding.Derive(e, data)
+		}
+		b.mu.Lock()
+		last, ok := key.last[binding]
+		if ok && last.stale == stale && reflect.DeepEqual(last.v, v) {
+			b.mu.Unlock()
+			continue
+		}
+		key.last[binding] = shownValue{v: v, stale: stale}
+		b.mu.Unlock()
+
+		err := b.show(context, binding, v, stale)
+		if err != nil {
+			b.conn.logger.Error(fmt.Sprintf("cannot show data from %s: %s", src.name, err))
+		}
+	}
+}
+
+// show sends the title, image and feedback of a binding.
+func (b *Bindings) show(context string, binding *Binding, v any, stale bool) error {
+	if binding.Title != nil {
+		err := b.conn.Send(events.NewESSetTitle(context, binding.Title(v, stale), events.EventTargetBoth, 0))
+		if err != nil {
+			return err
+		}
+	}
+	if binding.Image != nil {
+		img, err := binding.Image(v, stale)
+		if err != nil {
+			return err
+		}
+		payload, err := tools.EncodePNG(img, png.BestSpeed)
+		if err != nil {
+			return err
+		}
+		err = b.conn.Send(events.NewESSetImage(context, payload, events.EventTargetBoth, nil))
+		if err != nil {
+			return err
+		}
+	}
+	if binding.Feedback != nil {
+		ev, err := layout.NewFeedback(context, binding.Feedback(v, stale))
+		if err != nil {
+			return err
+		}
+		return b.conn.Send(ev)
+	}
+	return nil
+}
+
+// Name returns the name of the data source.
+func (src *DataSource) Name() string {
+	return src.name
+}
+
+// Get returns the data last fetched, when it was fetched, and the error of
+// the last fetch if it failed. fetched is zero until a fetch has succeeded.
+func (src *DataSource) Get() (data any, fetched time.Time, err error) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	return src.data, src.fetched, src.err
+}
+
+// Stale reports whether the last successful fetch is older than StaleAfter,
+// or there has not been one. Keys are updated as soon as the data goes stale,
+// even if a fetch is still under way.
+func (src *DataSource) Stale() bool {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	return src.fetched.IsZero() || time.Since(src.fetched) >= src.opts.StaleAfter
+}
+
+// Refresh fetches the data now, if the source is being polled, and resets
+// any backoff.
+func (src *DataSource) Refresh() {
+	select {
+	case src.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// start begins polling. The caller must hold the lock of the Bindings.
+func (src *DataSource) start(b *Bindings) {
+	ctx, cancel := context.WithCancel(context.Background())
+	src.mu.Lock()
+	src.cancel = cancel
+	src.watchStale(b)
+	src.mu.Unlock()
+	go src.poll(ctx, b)
+}
+
+// stop stops polling.
+func (src *DataSource) stop() {
+	src.mu.Lock()
+	if src.cancel != nil {
+		src.cancel()
+		src.cancel = nil
+	}
+	if src.goStale != nil {
+		src.goStale.Stop()
+		src.goStale = nil
+	}
+	src.mu.Unlock()
+}
+
+// watchStale arranges for the keys to be updated when the data fetched goes
+// stale, replacing any earlier arrangement. The caller must hold the lock.
+func (src *DataSource) watchStale(b *Bindings) {
+	if src.goStale != nil {
+		src.goStale.Stop()
+		src.goStale = nil
+	}
+	if src.fetched.IsZero() {
+		return
+	}
+	left := src.opts.StaleAfter - time.Since(src.fetched)
+	if left <= 0 {
+		return
+	}
+	src.goStale = time.AfterFunc(left, func() { b.updateAll(src) })
+}
+
+func (src *DataSource) poll(ctx context.Context, b *Bindings) {
+	failures := 0
+	for {
+		data, err := src.fetch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		src.mu.Lock()
+		src.err = err
+		if err == nil {
+			src.data = data
+			src.fetched = time.Now()
+			src.watchStale(b)
+			failures = 0
+		} else {
+			failures++
+		}
+		src.mu.Unlock()
+
+		if err != nil {
+			b.conn.logger.Error(fmt.Sprintf("cannot fetch %s: %s", src.name, err))
+		}
+		b.updateAll(src)
+
+		t := time.NewTimer(src.backoff(failures))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-src.refresh:
+			t.Stop()
+			failures = 0
+		case <-t.C:
+		}
+	}
+}
+
+// backoff returns the time to wait before the next fetch.
+func (src *DataSource) backoff(failures int) time.Duration {
+	d := src.opts.Interval
+	for i := 0; i < failures && d < src.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, src.opts.MaxBackoff)
+}
@@ -0,0 +1,253 @@
+package streamdeck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+func TestBindings(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	b := NewBindings(&c)
+
+	mu := sync.Mutex{}
+	fetches := 0
+	fail := false
+	src, err := b.AddSource("weather", func(ctx context.Context) (any, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		if fail {
+			return nil, errors.New("offline")
+		}
+		// the temperature changes with every fetch, the humidity never does
+		return map[string]int{"temp": fetches, "humidity": 40}, nil
+	}, DataSourceOptions{Interval: 50 * time.Millisecond, StaleAfter: 120 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.AddSource("weather", nil, DataSourceOptions{}); err == nil {
+		t.Error("expected an error for a duplicate source")
+	}
+	if err := b.Bind("com.example.temp", Binding{Source: "nope", Title: func(any, bool) string { return "" }}); err == nil {
+		t.Error("expected an error for an unknown source")
+	}
+
+	field := func(name string) func(events.ERWillAppear, any) any {
+		return func(e events.ERWillAppear, data any) any { return data.(map[string]int)[name] }
+	}
+	title := func(prefix string) func(any, bool) string {
+		return func(v any, stale bool) string {
+			if stale {
+				return prefix + "?"
+			}
+			return prefix + strings.Repeat("|", v.(int))
+		}
+	}
+	b.Bind("com.example.temp", Binding{Source: "weather", Derive: field("temp"), Title: title("T")})
+	b.Bind("com.example.humidity", Binding{Source: "weather", Derive: field("humidity"), Title: title("H")})
+
+	c.handle(events.ERWillAppear{ERCommon: events.ERCommon{Action: "com.example.temp", Context: "A"}})
+	c.handle(events.ERWillAppear{ERCommon: events.ERCommon{Action: "com.example.humidity", Context: "B"}})
+	time.Sleep(180 * time.Millisecond)
+
+	mu.Lock()
+	polled := fetches
+	fail = true
+	mu.Unlock()
+	if polled < 3 || polled > 5 {
+		t.Errorf("expected the source to be polled once for both keys, got %d fetches", polled)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if !src.Stale() {
+		t.Error("expected the data to be stale after failing")
+	}
+	if _, _, err := src.Get(); err == nil {
+		t.Error("expected the last error to be kept")
+	}
+
+	c.handle(events.ERWillDisappear{ERCommon: events.ERCommon{Action: "com.example.temp", Context: "A"}})
+	c.handle(events.ERWillDisappear{ERCommon: events.ERCommon{Action: "com.example.humidity", Context: "B"}})
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	stopped := fetches
+	mu.Unlock()
+	time.Sleep(150 * time.Millisecond)
+	mu.Lock()
+	if fetches != stopped {
+		t.Error("expected polling to stop when no key is shown")
+	}
+	mu.Unlock()
+	pluginEnd.Close()
+
+	titles := map[string][]string{}
+	for _, frame := range sentFrames(t, hostEnd) {
+		e := events.ESSetTitle{}
+		if err := json.Unmarshal([]byte(frame), &e); err != nil {
+			t.Fatal(err)
+		}
+		titles[e.Context] = append(titles[e.Context], e.Payload.Title)
+	}
+	if len(titles["A"]) != polled+1 || titles["A"][polled] != "T?" {
+		t.Errorf("expected a title for every temperature and for going stale, got %v", titles["A"])
+	}
+	if strings.Join(titles["B"], ",") != "H"+strings.Repeat("|", 40)+",H?" {
+		t.Errorf("expected the humidity to be shown only when it changed, got %v", titles["B"])
+	}
+}
+
+func TestBindingsStale(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	b := NewBindings(&c)
+
+	// the first fetch succeeds and every later one hangs until cancelled
+	mu := sync.Mutex{}
+	fetches := 0
+	b.AddSource("count", func(ctx context.Context) (any, error) {
+		mu.Lock()
+		fetches++
+		first := fetches == 1
+		mu.Unlock()
+		if first {
+			return 7, nil
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, DataSourceOptions{Interval: time.Millisecond, StaleAfter: 50 * time.Millisecond})
+	b.Bind("com.example.count", Binding{Source: "count", Title: func(v any, stale bool) string {
+		if stale {
+			return "stale"
+		}
+		return "fresh"
+	}})
+
+	titles := make(chan string, 100)
+	go func() {
+		defer close(titles)
+		for {
+			frame, err := hostEnd.ReadFrame()
+			if err != nil {
+				return
+			}
+			e := events.ESSetTitle{}
+			json.Unmarshal(frame, &e)
+			titles <- e.Payload.Title
+		}
+	}()
+	next := func() string {
+		t.Helper()
+		select {
+		case title := <-titles:
+			return title
+		case <-time.After(5 * time.Second):
+			t.Fatal("no title sent")
+		}
+		return ""
+	}
+	appear := events.ERWillAppear{ERCommon: events.ERCommon{Action: "com.example.count", Context: "A"}}
+	disappear := events.ERWillDisappear{ERCommon: events.ERCommon{Action: "com.example.count", Context: "A"}}
+
+	c.handle(appear)
+	if title := next(); title != "fresh" {
+		t.Fatalf("expected the data to be fresh at first, got %s", title)
+	}
+	// the key goes away and comes back while the data is still fresh, and
+	// it goes stale although the fetch never returns
+	c.handle(disappear)
+	c.handle(appear)
+	for title := next(); title != "stale"; title = next() {
+		if title != "fresh" {
+			t.Fatalf("unexpected title %s", title)
+		}
+	}
+
+	// once stale, a key coming back must not show the data as fresh
+	c.handle(disappear)
+	c.handle(appear)
+	if title := next(); title != "stale" {
+		t.Errorf("expected stale data on a key shown again, got %s", title)
+	}
+	c.handle(disappear)
+	pluginEnd.Close()
+}
+
+func TestDataSourceBackoff(t *testing.T) {
+	src := &DataSource{opts: DataSourceOptions{Interval: time.Second, MaxBackoff: 5 * time.Second}.withDefaults()}
+	for failures, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := src.backoff(failures); got != want {
+			t.Errorf("after %d failures expected %s, got %s", failures, want, got)
+		}
+	}
+}
+
+func TestBindingsLateBind(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	b := NewBindings(&c)
+	src, _ := b.AddSource("count", func(ctx context.Context) (any, error) {
+		return 7, nil
+	}, DataSourceOptions{Interval: time.Hour})
+	b.Bind("com.example.count", Binding{Source: "count", Title: func(v any, stale bool) string { return "a" }})
+
+	c.handle(events.ERWillAppear{ERCommon: events.ERCommon{Action: "com.example.count", Context: "A"}})
+	c.handle(events.ERWillAppear{ERCommon: events.ERCommon{Action: "com.example.other", Context: "B"}})
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		if _, fetched, _ := src.Get(); !fetched.IsZero() {
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatal("source not fetched")
+		}
+	}
+
+	// bindings added after keys appeared apply to them too
+	b.Bind("com.example.count", Binding{Source: "count", Title: func(v any, stale bool) string { return "b" }})
+	b.Bind("com.example.other", Binding{Source: "count", Title: func(v any, stale bool) string { return "c" }})
+	time.Sleep(20 * time.Millisecond)
+	c.handle(events.ERWillDisappear{ERCommon: events.ERCommon{Action: "com.example.count", Context: "A"}})
+	c.handle(events.ERWillDisappear{ERCommon: events.ERCommon{Action: "com.example.other", Context: "B"}})
+	pluginEnd.Close()
+
+	titles := map[string]bool{}
+	for _, frame := range sentFrames(t, hostEnd) {
+		e := events.ESSetTitle{}
+		json.Unmarshal([]byte(frame), &e)
+		titles[e.Context+e.Payload.Title] = true
+	}
+	for _, want := range []string{"Aa", "Ab", "Bc"} {
+		if !titles[want] {
+			t.Errorf("expected title %s, got %v", want, titles)
+		}
+	}
+}
+
+func TestBindingsImage(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	b := NewBindings(&c)
+	b.AddSource("count", func(ctx context.Context) (any, error) {
+		return 7, nil
+	}, DataSourceOptions{Interval: time.Hour})
+	b.Bind("com.example.count", Binding{Source: "count", Image: func(v any, stale bool) (image.Image, error) {
+		return image.NewRGBA(image.Rect(0, 0, 2, 2)), nil
+	}})
+	c.handle(events.ERWillAppear{ERCommon: events.ERCommon{Action: "com.example.count", Context: "A"}})
+	time.Sleep(50 * time.Millisecond)
+	c.handle(events.ERWillDisappear{ERCommon: events.ERCommon{Action: "com.example.count", Context: "A"}})
+	pluginEnd.Close()
+
+	sent := sentFrames(t, hostEnd)
+	if len(sent) != 1 || !strings.Contains(sent[0], `"image":"data:image/png;base64,`) {
+		t.Errorf("expected one image, got %v", sent)
+	}
+}
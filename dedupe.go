@@ -0,0 +1,201 @@
+package streamdeck
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+// SetDeduplication enables suppression of setTitle, setImage, setState and
+// setFeedback messages which would not change what the host is already
+// showing. The last value sent is remembered per context, and forgotten when
+// the context appears or disappears, or the connection is re-established.
+// The state of a key with multiple states, and any image sent without a
+// state, are also forgotten when the key is released, as the host moves the
+// key on to its next state by itself.
+//
+// Deduplication happens before coalescing (see SetCoalescing), so the two
+// can be used together.
+func (conn *Connection) SetDeduplication(enabled bool) {
+	if !enabled {
+		conn.dedupe = nil
+		return
+	}
+	if conn.dedupe != nil {
+		return
+	}
+	conn.dedupe = &sendCache{contexts: make(map[string]*contextCache)}
+}
+
+// titleKey identifies one of the titles or images a context can show.
+type titleKey struct {
+	state  int
+	target events.EventTarget
+}
+
+type contextCache struct {
+	titles   map[titleKey]string
+	images   map[titleKey]string
+	state    *int
+	feedback map[string]json.RawMessage
+}
+
+type sendCache struct {
+	mu       sync.Mutex
+	contexts map[string]*contextCache
+}
+
+// unchanged records the message as sent and reports whether the host
+// already had it. Messages which are not cached are never unchanged.
+func (c *sendCache) unchanged(e any) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch e := e.(type) {
+	case events.ESSetTitle:
+		cc := c.context(e.Context)
+		k := titleKey{state: e.Payload.State, target: e.Payload.Target}
+		return remember(cc.titles, k, e.Payload.Title)
+	case events.ESSetImage:
+		cc := c.context(e.Context)
+		k := titleKey{state: noState, target: e.Payload.Target}
+		if e.Payload.State != nil {
+			k.state = *e.Payload.State
+		}
+		return remember(cc.images, k, e.Payload.Image)
+	case events.ESSetState:
+		cc := c.context(e.Context)
+		if cc.state != nil && *cc.state == e.Payload.State {
+			return true
+		}
+		state := e.Payload.State
+		cc.state = &state
+	case events.ESSetFeedback:
+		update := map[string]json.RawMessage{}
+		if json.Unmarshal(e.Payload, &update) != nil {
+			return false
+		}
+		cc := c.context(e.Context)
+		same := true
+		for k, v := range update {
+			if prev, ok := cc.feedback[k]; !ok || !bytes.Equal(compactJSON(prev), compactJSON(v)) {
+				same = false
+			}
+			cc.feedback[k] = v
+		}
+		return same
+	case events.ESSetFeedbackLayout:
+		// a new layout starts with fresh values
+		if cc, ok := c.contexts[e.Context]; ok {
+			cc.feedback = make(map[string]json.RawMessage)
+		}
+	}
+	return false
+}
+
+// remember records a title or image and reports whether the host already
+// showed it. Sending to both targets replaces whatever was sent to either one
+// alone, and sending to one target means the value sent to both no longer
+// holds, so those entries are dropped rather than compared.
+func remember(m map[titleKey]string, k titleKey, v string) bool {
+	if prev, ok := m[k]; ok && prev == v {
+		return true
+	}
+	m[k] = v
+	if k.target == events.EventTargetBoth {
+		delete(m, titleKey{state: k.state, target: events.EventTargetHardware})
+		delete(m, titleKey{state: k.state, target: events.EventTargetSoftware})
+	} else {
+		delete(m, titleKey{state: k.state, target: events.EventTargetBoth})
+	}
+	return false
+}
+
+// seen updates the cache for an incoming event. It is called before any
+// interceptor, observer or handler sees the event, so that anything they send
+// in response is compared with what the host now has.
+func (c *sendCache) seen(event any) {
+	switch e := event.(type) {
+	case events.ERWillAppear:
+		c.forget(e.Context)
+	case events.ERWillDisappear:
+		c.forget(e.Context)
+	case events.ERKeyUp:
+		if e.Payload.State != nil {
+			c.forgetState(e.Context)
+		}
+	}
+}
+
+// context returns the cache for a context, creating it if needed. The caller
+// must hold the lock.
+func (c *sendCache) context(context string) *contextCache {
+	cc, ok := c.contexts[context]
+	if !ok {
+		cc = &contextCache{
+			titles:   make(map[titleKey]string),
+			images:   make(map[titleKey]string),
+			feedback: make(map[string]json.RawMessage),
+		}
+		c.contexts[context] = cc
+	}
+	return cc
+}
+
+// forget drops everything remembered about a context.
+func (c *sendCache) forget(context string) {
+	c.mu.Lock()
+	delete(c.contexts, context)
+	c.mu.Unlock()
+}
+
+// forgetState drops the state of a context, and the images sent without a
+// state, which showed whatever state the key was in.
+func (c *sendCache) forgetState(context string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cc, ok := c.contexts[context]
+	if !ok {
+		return
+	}
+	cc.state = nil
+	for k := range cc.images {
+		if k.state == noState {
+			delete(cc.images, k)
+		}
+	}
+}
+
+// reset drops everything remembered about every context.
+func (c *sendCache) reset() {
+	c.mu.Lock()
+	c.contexts = make(map[string]*contextCache)
+	c.mu.Unlock()
+}
+
+// compactJSON strips insignificant whitespace so that equal values compare
+// equal regardless of how they were formatted.
+func compactJSON(b []byte) []byte {
+	out := bytes.Buffer{}
+	if json.Compact(&out, b) != nil {
+		return b
+	}
+	return out.Bytes()
+}
+
+// contextOf returns the context a message is addressed to, if it has one.
+func contextOf(e any) string {
+	switch e := e.(type) {
+	case events.ESSetTitle:
+		return e.Context
+	case events.ESSetImage:
+		return e.Context
+	case events.ESSetState:
+		return e.Context
+	case events.ESSetFeedback:
+		return e.Context
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package streamdeck
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+func TestDeduplication(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	c.SetDeduplication(true)
+
+	c.Send(events.NewESSetTitle("ABC123", "1", events.EventTargetBoth, 0))
+	c.Send(events.NewESSetTitle("ABC123", "1", events.EventTargetBoth, 0))
+	c.Send(events.NewESSetFeedback("ABC123", json.RawMessage(`{"title":"a","value":1}`)))
+	c.Send(events.NewESSetFeedback("ABC123", json.RawMessage(`{"value": 1}`)))
+	c.handle(events.ERWillAppear{ERCommon: events.ERCommon{Context: "ABC123"}})
+	c.Send(events.NewESSetTitle("ABC123", "1", events.EventTargetBoth, 0))
+	pluginEnd.Close()
+
+	sent := sentFrames(t, hostEnd)
+
+	expected := []string{
+		`{"event":"setTitle","context":"ABC123","payload":{"title":"1","target":0,"state":0}}`,
+		`{"event":"setFeedback","context":"ABC123","payload":{"title":"a","value":1}}`,
+		`{"event":"setTitle","context":"ABC123","payload":{"title":"1","target":0,"state":0}}`,
+	}
+	checkFrames(t, sent, expected)
+}
+
+func TestDeduplicationImages(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	c.SetDeduplication(true)
+	one := 1
+
+	c.Send(events.NewESSetImage("ABC123", "a", events.EventTargetBoth, nil))
+	c.Send(events.NewESSetImage("ABC123", "a", events.EventTargetBoth, nil))
+	c.Send(events.NewESSetImage("ABC123", "a", events.EventTargetBoth, &one))
+	c.Send(events.NewESSetImage("ABC123", "a", events.EventTargetHardware, nil))
+	c.handle(events.ERWillDisappear{ERCommon: events.ERCommon{Context: "ABC123"}})
+	c.Send(events.NewESSetImage("ABC123", "a", events.EventTargetBoth, nil))
+	pluginEnd.Close()
+
+	checkFrames(t, sentFrames(t, hostEnd), []string{
+		`{"event":"setImage","context":"ABC123","payload":{"image":"a","target":0}}`,
+		`{"event":"setImage","context":"ABC123","payload":{"image":"a","target":0,"state":1}}`,
+		`{"event":"setImage","context":"ABC123","payload":{"image":"a","target":1}}`,
+		`{"event":"setImage","context":"ABC123","payload":{"image":"a","target":0}}`,
+	})
+}
+
+func TestDeduplicationTargets(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	c.SetDeduplication(true)
+
+	c.Send(events.NewESSetTitle("ABC123", "x", events.EventTargetBoth, 0))
+	c.Send(events.NewESSetTitle("ABC123", "y", events.EventTargetHardware, 0))
+	c.Send(events.NewESSetTitle("ABC123", "x", events.EventTargetBoth, 0))
+	c.Send(events.NewESSetImage("ABC123", "a", events.EventTargetSoftware, nil))
+	c.Send(events.NewESSetImage("ABC123", "b", events.EventTargetBoth, nil))
+	c.Send(events.NewESSetImage("ABC123", "a", events.EventTargetSoftware, nil))
+	pluginEnd.Close()
+
+	checkFrames(t, sentFrames(t, hostEnd), []string{
+		`{"event":"setTitle","context":"ABC123","payload":{"title":"x","target":0,"state":0}}`,
+		`{"event":"setTitle","context":"ABC123","payload":{"title":"y","target":1,"state":0}}`,
+		`{"event":"setTitle","context":"ABC123","payload":{"title":"x","target":0,"state":0}}`,
+		`{"event":"setImage","context":"ABC123","payload":{"image":"a","target":2}}`,
+		`{"event":"setImage","context":"ABC123","payload":{"image":"b","target":0}}`,
+		`{"event":"setImage","context":"ABC123","payload":{"image":"a","target":2}}`,
+	})
+}
+
+func TestDeduplicationKeyUp(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	c.SetDeduplication(true)
+	one := 1
+
+	// the host moves a multi-state key on when it is released, so the same
+	// state and state-less image must be sent again
+	c.Send(events.NewESSetState("ABC123", 0))
+	c.Send(events.NewESSetImage("ABC123", "a", events.EventTargetBoth, nil))
+	c.Send(events.NewESSetImage("ABC123", "b", events.EventTargetBoth, &one))
+	c.handle(statefulKeyUp("ABC123", 0, nil, false))
+	c.Send(events.NewESSetState("ABC123", 0))
+	c.Send(events.NewESSetImage("ABC123", "a", events.EventTargetBoth, nil))
+	c.Send(events.NewESSetImage("ABC123", "b", events.EventTargetBoth, &one))
+	pluginEnd.Close()
+
+	checkFrames(t, sentFrames(t, hostEnd), []string{
+		`{"event":"setState","context":"ABC123","payload":{"state":0}}`,
+		`{"event":"setImage","context":"ABC123","payload":{"image":"a","target":0}}`,
+		`{"event":"setImage","context":"ABC123","payload":{"image":"b","target":0,"state":1}}`,
+		`{"event":"setState","context":"ABC123","payload":{"state":0}}`,
+		`{"event":"setImage","context":"ABC123","payload":{"image":"a","target":0}}`,
+	})
+}
+
+func TestDeduplicationStatefulAction(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	c.SetDeduplication(true)
+	s := NewStatefulAction(&c, "com.example.mute", StatefulOptions{
+		Apply: func(context string, desired int) (int, error) {
+			return 0, errors.New("backend down")
+		},
+	})
+
+	c.handle(statefulAppear("A", 0))
+	s.Set("A", 0)
+	// the refused toggle must be undone, although state 0 was last sent
+	c.handle(statefulKeyUp("A", 0, nil, false))
+	pluginEnd.Close()
+
+	checkFrames(t, sentFrames(t, hostEnd), []string{
+		`{"event":"setState","context":"A","payload":{"state":0}}`,
+		`{"event":"setState","context":"A","payload":{"state":0}}`,
+	})
+}
@@ -0,0 +1,350 @@
+package streamdeck
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+	"github.com/tardisx/streamdeck-plugin/layout"
+	"github.com/tardisx/streamdeck-plugin/tools"
+)
+
+// accelerationIdle is how long a dial must be still before rotation is
+// treated as starting again at normal speed.
+const accelerationIdle = 250 * time.Millisecond
+
+// EncoderOptions describes the value controlled by a dial.
+type EncoderOptions struct {
+	Min, Max float64
+	// Initial is the value before the user has turned the dial, unless a
+	// value is found in the settings. It is clamped to Min and Max.
+	Initial float64
+	// Step is the change for each tick, defaults to 1. PressedStep is used
+	// instead while the dial is held down, defaults to Step.
+	Step        float64
+	PressedStep float64
+	// Wrap makes the value wrap around, rather than stop at the ends. Max
+	// itself wraps to Min, as for an angle.
+	Wrap bool
+
+	// Acceleration is the most the step is multiplied by when the dial is
+	// turned quickly, 1 or less disables it. The full multiplier is reached
+	// at AccelerationRate ticks a second, which defaults to 30.
+	Acceleration     float64
+	AccelerationRate float64
+
+	// Interval gathers changes over this period into one call of OnChange,
+	// one feedback update and one settings update. Zero makes every tick
+	// report at once.
+	Interval time.Duration
+	// OnChange is called after the value changes.
+	OnChange func(e *Encoder)
+
+	// ValueKey and IndicatorKey are the feedback items showing the value as
+	// text and as a bar from 0 to 100, defaulting to the "value" and
+	// "indicator" items of the built in layouts. NoFeedback disables
+	// feedback updates.
+	ValueKey     string
+	IndicatorKey string
+	NoFeedback   bool
+	// Format formats the value for the ValueKey item. The default shows as
+	// many decimal places as Step has.
+	Format func(v float64) string
+
+	// SettingsKey, if set, stores the value in the context's settings under
+	// this key, and restores it when the action appears.
+	SettingsKey string
+}
+
+func (o EncoderOptions) withDefaults() EncoderOptions {
+	if o.Step == 0 {
+		o.Step = 1
+	}
+	if o.PressedStep == 0 {
+		o.PressedStep = o.Step
+	}
+	if o.AccelerationRate <= 0 {
+		o.AccelerationRate = 30
+	}
+	if o.ValueKey == "" {
+		o.ValueKey = layout.KeyValue
+	}
+	if o.IndicatorKey == "" {
+		o.IndicatorKey = layout.KeyIndicator
+	}
+	if o.Format == nil {
+		places := 0
+		s := strconv.FormatFloat(math.Abs(o.Step), 'f', -1, 64)
+		if i := strings.IndexByte(s, '.'); i >= 0 {
+			places = len(s) - i - 1
+		}
+		o.Format = func(v float64) string {
+			return strconv.FormatFloat(v, 'f', places, 64)
+		}
+	}
+	return o
+}
+
+// Encoders keeps a value for every instance of a dial action, changed by
+// turning the dial. An Encoder is created for each context as it appears,
+// and removed when it disappears.
+type Encoders struct {
+	conn   *Connection
+	action string
+	opts   EncoderOptions
+
+	mu       sync.Mutex
+	encoders map[string]*Encoder
+}
+
+// Encoder is the value controlled by the dial of one context.
+type Encoder struct {
+	parent  *Encoders
+	context string
+
+	mu       sync.Mutex
+	value    float64
+	reported float64
+	settings json.RawMessage
+	lastTurn time.Time
+	timer    *time.Timer
+	gone     bool
+}
+
+// NewEncoders creates an Encoders for instances of the action with the given
+// UUID. It should be created before Connect, so that it sees every
+// willAppear.
+func NewEncoders(conn *Connection, action string, opts EncoderOptions) *Encoders {
+	es := &Encoders{
+		conn:     conn,
+		action:   action,
+		opts:     opts.withDefaults(),
+		encoders: make(map[string]*Encoder),
+	}
+	conn.observe(func(event any) {
+		switch e := event.(type) {
+		case events.ERWillAppear:
+			if e.Action == action && e.Payload.Controller == events.ControllerEncoder {
+				es.appear(e.Context, e.Payload.Settings)
+			}
+		case events.ERWillDisappear:
+			if e.Action == action {
+				es.disappear(e.Context)
+			}
+		case events.ERDidReceiveSettings:
+			es.settings(e.Context, e.Payload.Settings)
+		case events.ERDialRotate:
+			if enc, ok := es.Get(e.Context); ok {
+				enc.rotate(e.Payload.Ticks, e.Payload.Pressed)
+			}
+		}
+	})
+	conn.observeSent(func(event any) {
+		// settings saved by the plugin itself must be kept too
+		if e, ok := event.(events.ESSetSettings); ok {
+			es.settings(e.Context, e.Payload)
+		}
+	})
+	return es
+}
+
+// Get returns the Encoder for a context.
+func (es *Encoders) Get(context string) (*Encoder, bool) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	enc, ok := es.encoders[context]
+	return enc, ok
+}
+
+func (es *Encoders) appear(context string, settings json.RawMessage) {
+	enc := &Encoder{parent: es, context: context, settings: settings}
+	v := es.opts.Initial
+	if es.opts.SettingsKey != "" {
+		stored := map[string]json.RawMessage{}
+		if json.Unmarshal(settings, &stored) == nil {
+			json.Unmarshal(stored[es.opts.SettingsKey], &v)
+		}
+	}
+	enc.value = es.clamp(v)
+	enc.reported = enc.value
+
+	es.mu.Lock()
+	if old, ok := es.encoders[context]; ok {
+		old.stop()
+	}
+	es.encoders[context] = enc
+	es.mu.Unlock()
+
+	enc.report(enc.value, false)
+}
+
+// settings keeps the copy of a context's settings current, so that the value
+// can be merged into them.
+func (es *Encoders) settings(context string, settings json.RawMessage) {
+	if enc, ok := es.Get(context); ok {
+		enc.mu.Lock()
+		enc.settings = settings
+		enc.mu.Unlock()
+	}
+}
+
+func (es *Encoders) disappear(context string) {
+	es.mu.Lock()
+	enc, ok := es.encoders[context]
+	delete(es.encoders, context)
+	es.mu.Unlock()
+	if ok {
+		enc.stop()
+	}
+}
+
+// clamp keeps v within the range, without wrapping.
+func (es *Encoders) clamp(v float64) float64 {
+	return math.Max(es.opts.Min, math.Min(es.opts.Max, v))
+}
+
+// Context returns the context of the dial.
+func (e *Encoder) Context() string {
+	return e.context
+}
+
+// Value returns the current value.
+func (e *Encoder) Value() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.value
+}
+
+// Fraction returns where the value lies between Min and Max, from 0 to 1.
+func (e *Encoder) Fraction() float64 {
+	return tools.Fraction(e.Value(), e.parent.opts.Min, e.parent.opts.Max)
+}
+
+// Set changes the value, clamping it to the range, and reports the change as
+// if the dial had been turned.
+func (e *Encoder) Set(v float64) {
+	e.mu.Lock()
+	e.value = e.parent.clamp(v)
+	e.mu.Unlock()
+	e.changed()
+}
+
+// rotate applies ticks of the dial.
+func (e *Encoder) rotate(ticks int, pressed bool) {
+	opts := e.parent.opts
+	step := opts.Step
+	if pressed {
+		step = opts.PressedStep
+	}
+
+	e.mu.Lock()
+	now := time.Now()
+	if opts.Acceleration > 1 && !e.lastTurn.IsZero() {
+		if dt := now.Sub(e.lastTurn); dt < accelerationIdle {
+			rate := math.Abs(float64(ticks)) / math.Max(dt.Seconds(), 0.01)
+			step *= 1 + (opts.Acceleration-1)*math.Min(1, rate/opts.AccelerationRate)
+		}
+	}
+	e.lastTurn = now
+
+	v := e.value + float64(ticks)*step
+	if opts.Wrap && opts.Max > opts.Min {
+		span := opts.Max - opts.Min
+		v = opts.Min + math.Mod(math.Mod(v-opts.Min, span)+span, span)
+	}
+	e.value = e.parent.clamp(v)
+	e.mu.Unlock()
+
+	e.changed()
+}
+
+// changed reports the value now, or at the end of the interval.
+func (e *Encoder) changed() {
+	interval := e.parent.opts.Interval
+	if interval <= 0 {
+		e.flush()
+		return
+	}
+	e.mu.Lock()
+	if e.timer == nil && !e.gone {
+		e.timer = time.AfterFunc(interval, e.flush)
+	}
+	e.mu.Unlock()
+}
+
+// flush reports the value if it has changed since it was last reported.
+func (e *Encoder) flush() {
+	e.mu.Lock()
+	e.timer = nil
+	v := e.value
+	changed := v != e.reported && !e.gone
+	e.reported = v
+	e.mu.Unlock()
+
+	if changed {
+		e.report(v, true)
+	}
+}
+
+// report updates the feedback, and if persist is set the settings and
+// OnChange, with a new value.
+func (e *Encoder) report(v float64, persist bool) {
+	opts := e.parent.opts
+	conn := e.parent.conn
+	if !opts.NoFeedback {
+		payload, err := json.Marshal(map[string]any{
+			opts.ValueKey:     layout.Text{Value: opts.Format(v)},
+			opts.IndicatorKey: layout.Bar{Value: math.Round(tools.Fraction(v, opts.Min, opts.Max) * 100)},
+		})
+		if err == nil {
+			err = conn.Send(events.NewESSetFeedback(e.context, payload))
+		}
+		if err != nil {
+			conn.logger.Error("cannot update encoder feedback: " + err.Error())
+		}
+	}
+	if !persist {
+		return
+	}
+	if opts.SettingsKey != "" {
+		e.persist(v)
+	}
+	if opts.OnChange != nil {
+		opts.OnChange(e)
+	}
+}
+
+// persist stores the value in the settings, keeping any other settings.
+func (e *Encoder) persist(v float64) {
+	e.mu.Lock()
+	settings := map[string]json.RawMessage{}
+	json.Unmarshal(e.settings, &settings)
+	settings[e.parent.opts.SettingsKey], _ = json.Marshal(v)
+	b, err := json.Marshal(settings)
+	if err == nil {
+		e.settings = b
+	}
+	e.mu.Unlock()
+
+	if err == nil {
+		err = e.parent.conn.Send(events.NewESSetSettings(e.context, b))
+	}
+	if err != nil {
+		e.parent.conn.logger.Error("cannot save encoder value: " + err.Error())
+	}
+}
+
+// stop cancels any pending report.
+func (e *Encoder) stop() {
+	e.mu.Lock()
+	e.gone = true
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.mu.Unlock()
+}
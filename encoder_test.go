@@ -0,0 +1,138 @@
+package streamdeck
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+func rotate(context string, ticks int, pressed bool) events.ERDialRotate {
+	e := events.ERDialRotate{ERCommon: events.ERCommon{Action: "com.example.volume", Context: context}}
+	e.Payload.Ticks = ticks
+	e.Payload.Pressed = pressed
+	return e
+}
+
+func dialAppear(c *Connection, context string, settings string) {
+	e := events.ERWillAppear{ERCommon: events.ERCommon{Action: "com.example.volume", Context: context}}
+	e.Payload.Controller = events.ControllerEncoder
+	e.Payload.Settings = json.RawMessage(settings)
+	c.handle(e)
+}
+
+func TestEncoder(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	changes := []float64{}
+	es := NewEncoders(&c, "com.example.volume", EncoderOptions{
+		Min: 0, Max: 10, Step: 0.5, PressedStep: 2,
+		SettingsKey: "volume",
+		OnChange:    func(e *Encoder) { changes = append(changes, e.Value()) },
+	})
+
+	dialAppear(&c, "ABC123", `{"volume":4,"device":"speakers"}`)
+	enc, ok := es.Get("ABC123")
+	if !ok || enc.Value() != 4 {
+		t.Fatalf("expected the value to be restored from settings")
+	}
+	c.handle(rotate("ABC123", 3, false))
+	c.handle(rotate("ABC123", -1, true))
+	c.handle(rotate("ABC123", 100, false))
+	pluginEnd.Close()
+
+	if enc.Value() != 10 {
+		t.Errorf("expected the value to be clamped, got %g", enc.Value())
+	}
+	if len(changes) != 3 || changes[0] != 5.5 || changes[1] != 3.5 {
+		t.Errorf("wrong changes %v", changes)
+	}
+
+	sent := sentFrames(t, hostEnd)
+	expected := []string{
+		`{"event":"setFeedback","context":"ABC123","payload":{"indicator":{"value":40},"value":{"value":"4.0"}}}`,
+		`{"event":"setFeedback","context":"ABC123","payload":{"indicator":{"value":55},"value":{"value":"5.5"}}}`,
+		`{"event":"setSettings","context":"ABC123","Payload":{"device":"speakers","volume":5.5}}`,
+	}
+	if len(sent) != 7 {
+		t.Fatalf("expected 7 messages, got %v", sent)
+	}
+	checkFrames(t, sent[:len(expected)], expected)
+}
+
+func TestEncoderWrapAndAccelerate(t *testing.T) {
+	c := New()
+	es := NewEncoders(&c, "com.example.volume", EncoderOptions{Min: 0, Max: 360, Step: 10, Wrap: true, NoFeedback: true})
+	dialAppear(&c, "ABC123", `{}`)
+	enc, _ := es.Get("ABC123")
+	c.handle(rotate("ABC123", -2, false))
+	if enc.Value() != 340 {
+		t.Errorf("expected to wrap to 340, got %g", enc.Value())
+	}
+	c.handle(rotate("ABC123", 3, false))
+	if enc.Value() != 10 {
+		t.Errorf("expected to wrap to 10, got %g", enc.Value())
+	}
+
+	es = NewEncoders(&c, "com.example.fast", EncoderOptions{Min: 0, Max: 1000, Acceleration: 5, NoFeedback: true})
+	e := events.ERWillAppear{ERCommon: events.ERCommon{Action: "com.example.fast", Context: "DEF456"}}
+	e.Payload.Controller = events.ControllerEncoder
+	c.handle(e)
+	enc, _ = es.Get("DEF456")
+	fast := rotate("DEF456", 1, false)
+	fast.Action = "com.example.fast"
+	c.handle(fast)
+	c.handle(fast)
+	if v := enc.Value(); v != 6 {
+		t.Errorf("expected the second quick tick to be accelerated to 5, got a total of %g", v)
+	}
+	time.Sleep(accelerationIdle + 10*time.Millisecond)
+	c.handle(fast)
+	if v := enc.Value(); v != 7 {
+		t.Errorf("expected a slow tick to be 1, got a total of %g", v)
+	}
+}
+
+func TestEncoderInterval(t *testing.T) {
+	c := New()
+	changed := make(chan float64, 10)
+	NewEncoders(&c, "com.example.volume", EncoderOptions{
+		Min: 0, Max: 100, Interval: 30 * time.Millisecond, NoFeedback: true,
+		OnChange: func(e *Encoder) { changed <- e.Value() },
+	})
+	dialAppear(&c, "ABC123", `{}`)
+	for i := 0; i < 5; i++ {
+		c.handle(rotate("ABC123", 1, false))
+	}
+	select {
+	case v := <-changed:
+		if v != 5 {
+			t.Errorf("expected one change to 5, got %g", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no change reported")
+	}
+	select {
+	case v := <-changed:
+		t.Errorf("unexpected change to %g", v)
+	case <-time.After(60 * time.Millisecond):
+	}
+}
+
+func TestEncoderKeepsSentSettings(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	NewEncoders(&c, "com.example.volume", EncoderOptions{Min: 0, Max: 10, SettingsKey: "volume", NoFeedback: true})
+
+	dialAppear(&c, "ABC123", `{"volume":4}`)
+	// the plugin saves a setting of its own
+	c.Send(events.NewESSetSettings("ABC123", json.RawMessage(`{"device":"headphones","volume":4}`)))
+	c.handle(rotate("ABC123", 1, false))
+	pluginEnd.Close()
+
+	checkFrames(t, sentFrames(t, hostEnd), []string{
+		`{"event":"setSettings","context":"ABC123","Payload":{"device":"headphones","volume":4}}`,
+		`{"event":"setSettings","context":"ABC123","Payload":{"device":"headphones","volume":5}}`,
+	})
+}
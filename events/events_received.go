@@ -8,6 +8,9 @@ import (
 // ValidEventType returns a boolean indicating whether or not
 // this is a valid event type
 func ValidEventType(t reflect.Type) bool {
+	if syntheticEventTypes[t] {
+		return true
+	}
 	for i := range receivedEventTypeMap {
 		if receivedEventTypeMap[i] == t {
 			return true
@@ -244,20 +247,23 @@ type ERTitleParametersDidChange struct {
 			Column int `json:"column"`
 			Row    int `json:"row"`
 		} `json:"coordinates"`
-		State           int    `json:"state"` // This value indicates which state of the action the title or title parameters have been changed.
-		Title           string `json:"title"` //The new title.
-		TitleParameters struct {
-			FontFamily     string `json:"fontFamily"`     //The font family for the title.
-			FontSize       int    `json:"fontSize"`       // The font size for the title.
-			FontStyle      string `json:"fontStyle"`      // The font style for the title
-			FontUnderline  bool   `json:"fontUnderline"`  //Boolean indicating an underline under the title
-			ShowTitle      bool   `json:"showTitle"`      //Boolean indicating if the title is visible
-			TitleAlignment string `json:"titleAlignment"` //Vertical alignment of the title. Possible values are "top", "bottom" and "middle".
-			TitleColor     string `json:"titleColor"`     // Title color.
-		} `json:"titleParameters"`
+		State           int             `json:"state"` // This value indicates which state of the action the title or title parameters have been changed.
+		Title           string          `json:"title"` //The new title.
+		TitleParameters TitleParameters `json:"titleParameters"`
 	} `json:"payload"`
 }
 
+// TitleParameters describe how the user has chosen to display the title of an action
+type TitleParameters struct {
+	FontFamily     string `json:"fontFamily"`     //The font family for the title.
+	FontSize       int    `json:"fontSize"`       // The font size for the title.
+	FontStyle      string `json:"fontStyle"`      // The font style for the title
+	FontUnderline  bool   `json:"fontUnderline"`  //Boolean indicating an underline under the title
+	ShowTitle      bool   `json:"showTitle"`      //Boolean indicating if the title is visible
+	TitleAlignment string `json:"titleAlignment"` //Vertical alignment of the title. Possible values are "top", "bottom" and "middle".
+	TitleColor     string `json:"titleColor"`     // Title color.
+}
+
 // ERDeviceDidConnect - When a device is plugged into the computer, the plugin will receive a deviceDidConnect event
 // https://docs.elgato.com/sdk/plugins/events-received#devicedidconnect
 type ERDeviceDidConnect struct {
@@ -266,7 +272,7 @@ type ERDeviceDidConnect struct {
 	Device     string `json:"device"`
 	DeviceInfo struct {
 		Name       string `json:"name"` // The name of the device set by the user.
-		DeviceType int    `json:"type"` // Type of device. Possible values are kESDSDKDeviceType_StreamDeck (0), kESDSDKDeviceType_StreamDeckMini (1), kESDSDKDeviceType_StreamDeckXL (2), kESDSDKDeviceType_StreamDeckMobile (3) and kESDSDKDeviceType_CorsairGKeys (4)
+		DeviceType int    `json:"type"` // Type of device. One of the DeviceType constants, such as DeviceTypeStreamDeck (0) or DeviceTypeStreamDeckPlus (7)
 		Size       struct {
 			Columns int `json:"columns"`
 			Rows    int `json:"rows"`
@@ -274,6 +280,27 @@ type ERDeviceDidConnect struct {
 	} `json:"deviceInfo"`
 }
 
+// Device types, as found in ERDeviceDidConnect.DeviceInfo.DeviceType
+const (
+	DeviceTypeStreamDeck       = 0
+	DeviceTypeStreamDeckMini   = 1
+	DeviceTypeStreamDeckXL     = 2
+	DeviceTypeStreamDeckMobile = 3
+	DeviceTypeCorsairGKeys     = 4
+	DeviceTypeStreamDeckPedal  = 5
+	DeviceTypeCorsairVoyager   = 6
+	DeviceTypeStreamDeckPlus   = 7
+	DeviceTypeSCUFController   = 8
+	DeviceTypeStreamDeckNeo    = 9
+)
+
+// Controller types, as found in the Controller field of ERWillAppear and
+// other events
+const (
+	ControllerKeypad  = "Keypad"
+	ControllerEncoder = "Encoder"
+)
+
 // ERDeviceDidDisconnect - When a device is unplugged from the computer, the plugin will receive a deviceDidDisconnect event
 // https://docs.elgato.com/sdk/plugins/events-received#devicediddisconnect
 type ERDeviceDidDisconnect struct {
@@ -0,0 +1,84 @@
+package events
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// The events in this file are not sent by the Stream Deck application. They
+// are synthesised from ERKeyDown and ERKeyUp by streamdeck.KeyGestures and
+// streamdeck.Chords, and handlers for them are registered in the same way.
+
+func init() {
+	syntheticEventTypes[reflect.TypeOf(KeyShortPress{})] = true
+	syntheticEventTypes[reflect.TypeOf(KeyLongPress{})] = true
+	syntheticEventTypes[reflect.TypeOf(KeyDoublePress{})] = true
+	syntheticEventTypes[reflect.TypeOf(KeyRepeat{})] = true
+	syntheticEventTypes[reflect.TypeOf(KeyChord{})] = true
+}
+
+// syntheticEventTypes are valid for handlers, but never decoded from the
+// websocket.
+var syntheticEventTypes = map[reflect.Type]bool{}
+
+// KeyGesturePayload describes the key a gesture was made on.
+type KeyGesturePayload struct {
+	Settings    json.RawMessage `json:"settings"`
+	Coordinates struct {
+		Column int `json:"column"`
+		Row    int `json:"row"`
+	} `json:"coordinates"`
+	State           *int `json:"state"`
+	IsInMultiAction bool `json:"isInMultiAction"`
+}
+
+// KeyShortPress - the key was pressed and released before the long press
+// threshold, and was not followed by a second press in time to be a double
+// press.
+type KeyShortPress struct {
+	ERCommon
+	Payload KeyGesturePayload `json:"payload"`
+}
+
+// KeyLongPress - the key has been held for the long press threshold. It is
+// sent while the key is still down, and no short press follows when it is
+// released.
+type KeyLongPress struct {
+	ERCommon
+	Payload KeyGesturePayload `json:"payload"`
+	Held    time.Duration     `json:"held"`
+}
+
+// KeyDoublePress - the key was pressed twice in quick succession.
+type KeyDoublePress struct {
+	ERCommon
+	Payload KeyGesturePayload `json:"payload"`
+}
+
+// KeyRepeat - the key is being held, sent repeatedly like a held down
+// keyboard key. Count starts at 1.
+type KeyRepeat struct {
+	ERCommon
+	Payload KeyGesturePayload `json:"payload"`
+	Count   int               `json:"count"`
+}
+
+// KeyChord - every key of a chord is held down at once. The ERCommon is that
+// of the key which completed the chord, and Keys lists every key in the
+// order they were pressed.
+type KeyChord struct {
+	ERCommon
+	Chord string    `json:"chord"`
+	Keys  []HeldKey `json:"keys"`
+}
+
+// HeldKey is a key which is part of a KeyChord.
+type HeldKey struct {
+	Action      string `json:"action"`
+	Context     string `json:"context"`
+	Coordinates struct {
+		Column int `json:"column"`
+		Row    int `json:"row"`
+	} `json:"coordinates"`
+}
@@ -0,0 +1,39 @@
+package events
+
+// Info is the JSON passed to the plugin in the -info command line argument
+// when it is started by the Stream Deck application.
+// https://docs.elgato.com/sdk/plugins/registration-procedure#info-parameter
+type Info struct {
+	Application struct {
+		Font            string `json:"font"`
+		Language        string `json:"language"`
+		Platform        string `json:"platform"` // "mac" or "windows"
+		PlatformVersion string `json:"platformVersion"`
+		Version         string `json:"version"`
+	} `json:"application"`
+	Plugin struct {
+		UUID    string `json:"uuid"`
+		Version string `json:"version"`
+	} `json:"plugin"`
+	DevicePixelRatio int        `json:"devicePixelRatio"` // 2 on high-DPI screens
+	Colors           InfoColors `json:"colors"`
+	Devices          []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		Size struct {
+			Columns int `json:"columns"`
+			Rows    int `json:"rows"`
+		} `json:"size"`
+		Type int `json:"type"` // one of the DeviceType constants
+	} `json:"devices"`
+}
+
+// InfoColors are the user's colour preferences, as #RRGGBBAA strings.
+type InfoColors struct {
+	ButtonPressedBackgroundColor string `json:"buttonPressedBackgroundColor"`
+	ButtonPressedBorderColor     string `json:"buttonPressedBorderColor"`
+	ButtonPressedTextColor       string `json:"buttonPressedTextColor"`
+	DisabledColor                string `json:"disabledColor"`
+	HighlightColor               string `json:"highlightColor"`
+	MouseDownColor               string `json:"mouseDownColor"`
+}
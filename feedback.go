@@ -0,0 +1,204 @@
+package streamdeck
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+	"github.com/tardisx/streamdeck-plugin/layout"
+)
+
+// FeedbackState keeps track of what the touch strip of each context is
+// showing, so that feedback updates only send the item properties which
+// have changed. This matters for dials, where ERDialRotate can arrive dozens
+// of times a second.
+//
+// Every setFeedback sent on the connection is remembered, whether or not it
+// was sent through the FeedbackState. What a context shows is forgotten when
+// it appears, and when its layout is changed with setFeedbackLayout.
+type FeedbackState struct {
+	conn *Connection
+
+	mu       sync.Mutex
+	contexts map[string]*shownFeedback
+}
+
+type shownFeedback struct {
+	layout string
+	// items holds the properties of each item key, with simple values
+	// stored as the "value" property.
+	items map[string]map[string]json.RawMessage
+}
+
+// NewFeedbackState creates a FeedbackState. It should be created before
+// Connect, so that it sees every willAppear.
+func NewFeedbackState(conn *Connection) *FeedbackState {
+	s := &FeedbackState{
+		conn:     conn,
+		contexts: make(map[string]*shownFeedback),
+	}
+	conn.observe(func(event any) {
+		switch e := event.(type) {
+		case events.ERWillAppear:
+			s.forget(e.Context)
+		case events.ERWillDisappear:
+			s.forget(e.Context)
+		}
+	})
+	conn.observeSent(func(event any) {
+		switch e := event.(type) {
+		case events.ESSetFeedbackLayout:
+			s.mu.Lock()
+			s.contexts[e.Context] = &shownFeedback{
+				layout: e.Payload.Layout,
+				items:  make(map[string]map[string]json.RawMessage),
+			}
+			s.mu.Unlock()
+		case events.ESSetFeedback:
+			update, err := parseFeedback(e.Payload)
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.context(e.Context).merge(update)
+			s.mu.Unlock()
+		}
+	})
+	return s
+}
+
+// Update sends the parts of a setFeedback payload which differ from what the
+// context is already showing. Nothing is sent if nothing has changed.
+func (s *FeedbackState) Update(context string, payload json.RawMessage) error {
+	update, err := parseFeedback(payload)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	changed := s.context(context).diff(update)
+	s.mu.Unlock()
+	if len(changed) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(changed)
+	if err != nil {
+		return err
+	}
+	return s.conn.Send(events.NewESSetFeedback(context, b))
+}
+
+// Set sends the changed parts of typed feedback, see Update.
+func (s *FeedbackState) Set(context string, fb layout.Feedback) error {
+	payload, err := layout.Payload(fb)
+	if err != nil {
+		return err
+	}
+	return s.Update(context, payload)
+}
+
+// SetLayout changes the layout of a context. The new layout starts with its
+// default values, so the next update sends everything.
+func (s *FeedbackState) SetLayout(context string, l string) error {
+	return s.conn.Send(events.NewESSetFeedbackLayout(context, l))
+}
+
+// Layout returns the layout last set for a context with setFeedbackLayout.
+// It is empty if none has been set since the context appeared, in which case
+// the layout from the manifest is in use.
+func (s *FeedbackState) Layout(context string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sf, ok := s.contexts[context]; ok {
+		return sf.layout
+	}
+	return ""
+}
+
+// Shown returns what a context is showing, as a setFeedback payload.
+func (s *FeedbackState) Shown(context string) json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := map[string]map[string]json.RawMessage{}
+	if sf, ok := s.contexts[context]; ok {
+		items = sf.items
+	}
+	b, _ := json.Marshal(items)
+	return b
+}
+
+// context returns what a context is showing, creating it if needed. The
+// caller must hold the lock.
+func (s *FeedbackState) context(context string) *shownFeedback {
+	sf, ok := s.contexts[context]
+	if !ok {
+		sf = &shownFeedback{items: make(map[string]map[string]json.RawMessage)}
+		s.contexts[context] = sf
+	}
+	return sf
+}
+
+func (s *FeedbackState) forget(context string) {
+	s.mu.Lock()
+	delete(s.contexts, context)
+	s.mu.Unlock()
+}
+
+// diff returns the properties of update which differ from those shown.
+func (sf *shownFeedback) diff(update map[string]map[string]json.RawMessage) map[string]map[string]json.RawMessage {
+	changed := map[string]map[string]json.RawMessage{}
+	for key, props := range update {
+		shown := sf.items[key]
+		for prop, v := range props {
+			if prev, ok := shown[prop]; ok && bytes.Equal(prev, v) {
+				continue
+			}
+			if changed[key] == nil {
+				changed[key] = map[string]json.RawMessage{}
+			}
+			changed[key][prop] = v
+		}
+	}
+	return changed
+}
+
+// merge records update as shown.
+func (sf *shownFeedback) merge(update map[string]map[string]json.RawMessage) {
+	for key, props := range update {
+		shown, ok := sf.items[key]
+		if !ok {
+			shown = map[string]json.RawMessage{}
+			sf.items[key] = shown
+		}
+		for prop, v := range props {
+			shown[prop] = v
+		}
+	}
+}
+
+// parseFeedback splits a setFeedback payload into the properties of each
+// item. A simple value, such as "title": "Volume", is the same as
+// "title": {"value": "Volume"}. Values are compacted so they can be compared.
+func parseFeedback(payload json.RawMessage) (map[string]map[string]json.RawMessage, error) {
+	raw := map[string]json.RawMessage{}
+	err := json.Unmarshal(payload, &raw)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string]map[string]json.RawMessage, len(raw))
+	for key, v := range raw {
+		v = compactJSON(v)
+		props := map[string]json.RawMessage{}
+		if len(v) > 0 && v[0] == '{' && json.Unmarshal(v, &props) == nil {
+			for prop, pv := range props {
+				props[prop] = compactJSON(pv)
+			}
+		} else {
+			props["value"] = v
+		}
+		items[key] = props
+	}
+	return items, nil
+}
@@ -0,0 +1,43 @@
+package streamdeck
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+	"github.com/tardisx/streamdeck-plugin/layout"
+)
+
+func TestFeedbackState(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	s := NewFeedbackState(&c)
+
+	s.Update("ABC123", json.RawMessage(`{"title":"Volume","indicator":{"value":5,"bar_fill_c":"#ff0000"}}`))
+	s.Update("ABC123", json.RawMessage(`{"title":"Volume","indicator":{"value":6,"bar_fill_c":"#ff0000"}}`))
+	s.Set("ABC123", layout.B1{Title: &layout.Text{Value: "Volume"}, Indicator: &layout.Bar{Value: 6}})
+	// sent directly, but still remembered
+	c.Send(events.NewESSetFeedback("ABC123", json.RawMessage(`{"value":"6"}`)))
+	s.Update("ABC123", json.RawMessage(`{"value":{"value":"6"}}`))
+	s.SetLayout("ABC123", layout.LayoutA1)
+	s.Update("ABC123", json.RawMessage(`{"title":"Volume"}`))
+	c.handle(events.ERWillAppear{ERCommon: events.ERCommon{Context: "ABC123"}})
+	s.Update("ABC123", json.RawMessage(`{"title":"Volume"}`))
+	pluginEnd.Close()
+
+	if l := s.Layout("ABC123"); l != "" {
+		t.Errorf("expected the layout to be forgotten, got %s", l)
+	}
+
+	sent := sentFrames(t, hostEnd)
+
+	expected := []string{
+		`{"event":"setFeedback","context":"ABC123","payload":{"indicator":{"bar_fill_c":"#ff0000","value":5},"title":{"value":"Volume"}}}`,
+		`{"event":"setFeedback","context":"ABC123","payload":{"indicator":{"value":6}}}`,
+		`{"event":"setFeedback","context":"ABC123","payload":{"value":"6"}}`,
+		`{"event":"setFeedbackLayout","context":"ABC123","payload":{"layout":"$A1"}}`,
+		`{"event":"setFeedback","context":"ABC123","payload":{"title":{"value":"Volume"}}}`,
+		`{"event":"setFeedback","context":"ABC123","payload":{"title":{"value":"Volume"}}}`,
+	}
+	checkFrames(t, sent, expected)
+}
@@ -0,0 +1,255 @@
+package streamdeck
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+// GestureOptions controls how KeyGestures tells gestures apart.
+type GestureOptions struct {
+	// LongPress is how long a key must be held to be a long press.
+	// Defaults to 500ms.
+	LongPress time.Duration
+	// DoublePress is how soon a second press must start after the first is
+	// released to be a double press. A short press is only sent once this
+	// has passed, so it adds latency. Defaults to 300ms, negative disables
+	// double presses so that short presses are sent immediately.
+	DoublePress time.Duration
+	// RepeatInterval is the time between events.KeyRepeat events while a
+	// key is held. Zero, the default, disables repeating.
+	RepeatInterval time.Duration
+	// RepeatDelay is how long a key must be held before repeating starts.
+	// Defaults to LongPress.
+	RepeatDelay time.Duration
+}
+
+func (o GestureOptions) withDefaults() GestureOptions {
+	if o.LongPress <= 0 {
+		o.LongPress = 500 * time.Millisecond
+	}
+	if o.DoublePress == 0 {
+		o.DoublePress = 300 * time.Millisecond
+	}
+	if o.RepeatDelay <= 0 {
+		o.RepeatDelay = o.LongPress
+	}
+	return o
+}
+
+// KeyGestures synthesises events.KeyShortPress, events.KeyLongPress,
+// events.KeyDoublePress and events.KeyRepeat from the keyDown and keyUp of
+// each context. Register handlers for them with RegisterHandler as usual.
+// The keyDown and keyUp events are still passed to their handlers.
+//
+// Keys in a multi-action send keyUp immediately after keyDown, so they only
+// ever produce a short press, sent without waiting for a double press.
+//
+// Long presses and repeats are sent from a timer while the key is held, so
+// handlers for them run on a different goroutine to other handlers.
+type KeyGestures struct {
+	conn *Connection
+	opts GestureOptions
+
+	mu   sync.Mutex
+	keys map[string]*keyGesture
+}
+
+// The timers a key can have running.
+const (
+	timerLong = iota
+	timerRepeat
+	timerDouble
+)
+
+// keyGesture is the state of the key of one context.
+type keyGesture struct {
+	// seq is increased whenever a timer is cancelled, so that a timer which
+	// fires as it is stopped can tell it is stale.
+	seq     int
+	down    bool
+	downAt  time.Time
+	long    bool // a long press has been sent for this press
+	repeats int
+	pending bool // released once, waiting to see if it is a double press
+	timers  [3]*time.Timer
+	common  events.ERCommon
+	payload events.KeyGesturePayload
+}
+
+// NewKeyGestures creates a KeyGestures. It should be created before Connect.
+func NewKeyGestures(conn *Connection, opts GestureOptions) *KeyGestures {
+	g := &KeyGestures{
+		conn: conn,
+		opts: opts.withDefaults(),
+		keys: make(map[string]*keyGesture),
+	}
+	conn.observe(func(event any) {
+		switch e := event.(type) {
+		case events.ERKeyDown:
+			g.keyDown(e)
+		case events.ERKeyUp:
+			g.keyUp(e)
+		case events.ERWillDisappear:
+			g.mu.Lock()
+			if k, ok := g.keys[e.Context]; ok {
+				k.cancel()
+				delete(g.keys, e.Context)
+			}
+			g.mu.Unlock()
+		}
+	})
+	return g
+}
+
+func (g *KeyGestures) keyDown(e events.ERKeyDown) {
+	if e.Payload.IsInMultiAction {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	k, ok := g.keys[e.Context]
+	if !ok {
+		k = &keyGesture{}
+		g.keys[e.Context] = k
+	}
+	// a pending short press stays pending, keyUp or the long press timer
+	// decide what it was
+	k.cancel()
+	k.down = true
+	k.downAt = time.Now()
+	k.long = false
+	k.repeats = 0
+	k.common = e.ERCommon
+	k.payload = keyDownPayload(e)
+
+	seq := k.seq
+	k.after(timerLong, g.opts.LongPress, func() {
+		g.fire(e.Context, seq, func(k *keyGesture) []any {
+			k.long = true
+			long := events.KeyLongPress{ERCommon: withEvent(k.common, "keyLongPress"), Payload: k.payload, Held: time.Since(k.downAt)}
+			if k.pending {
+				// the first press will not become a double press now
+				k.pending = false
+				return []any{events.KeyShortPress{ERCommon: withEvent(k.common, "keyShortPress"), Payload: k.payload}, long}
+			}
+			return []any{long}
+		})
+	})
+	if g.opts.RepeatInterval > 0 {
+		k.after(timerRepeat, g.opts.RepeatDelay, func() { g.repeat(e.Context, seq) })
+	}
+}
+
+// repeat sends a KeyRepeat and schedules the next.
+func (g *KeyGestures) repeat(context string, seq int) {
+	g.fire(context, seq, func(k *keyGesture) []any {
+		k.repeats++
+		k.after(timerRepeat, g.opts.RepeatInterval, func() { g.repeat(context, seq) })
+		return []any{events.KeyRepeat{ERCommon: withEvent(k.common, "keyRepeat"), Payload: k.payload, Count: k.repeats}}
+	})
+}
+
+func (g *KeyGestures) keyUp(e events.ERKeyUp) {
+	if e.Payload.IsInMultiAction {
+		g.conn.handle(events.KeyShortPress{ERCommon: withEvent(e.ERCommon, "keyShortPress"), Payload: keyUpPayload(e)})
+		return
+	}
+
+	g.mu.Lock()
+	k, ok := g.keys[e.Context]
+	if !ok || !k.down {
+		// released without being seen pressed, perhaps before it appeared
+		g.mu.Unlock()
+		return
+	}
+	k.cancel()
+	k.down = false
+	k.payload = keyUpPayload(e)
+
+	var event any
+	switch {
+	case k.long || k.repeats > 0:
+		// already handled while held
+	case k.pending:
+		k.pending = false
+		event = events.KeyDoublePress{ERCommon: withEvent(k.common, "keyDoublePress"), Payload: k.payload}
+	case g.opts.DoublePress < 0:
+		event = events.KeyShortPress{ERCommon: withEvent(k.common, "keyShortPress"), Payload: k.payload}
+	default:
+		k.pending = true
+		seq := k.seq
+		k.after(timerDouble, g.opts.DoublePress, func() {
+			g.fire(e.Context, seq, func(k *keyGesture) []any {
+				k.pending = false
+				return []any{events.KeyShortPress{ERCommon: withEvent(k.common, "keyShortPress"), Payload: k.payload}}
+			})
+		})
+	}
+	g.mu.Unlock()
+
+	if event != nil {
+		g.conn.handle(event)
+	}
+}
+
+// fire runs fn for a context if its timer is still current, and passes the
+// events it returns to the handlers in order.
+func (g *KeyGestures) fire(context string, seq int, fn func(k *keyGesture) []any) {
+	g.mu.Lock()
+	k, ok := g.keys[context]
+	if !ok || k.seq != seq {
+		g.mu.Unlock()
+		return
+	}
+	evs := fn(k)
+	g.mu.Unlock()
+
+	for _, e := range evs {
+		g.conn.handle(e)
+	}
+}
+
+func withEvent(c events.ERCommon, event string) events.ERCommon {
+	c.Event = event
+	return c
+}
+
+func keyDownPayload(e events.ERKeyDown) events.KeyGesturePayload {
+	p := events.KeyGesturePayload{
+		Settings:        e.Payload.Settings,
+		State:           e.Payload.State,
+		IsInMultiAction: e.Payload.IsInMultiAction,
+	}
+	p.Coordinates = e.Payload.Coordinates
+	return p
+}
+
+func keyUpPayload(e events.ERKeyUp) events.KeyGesturePayload {
+	p := events.KeyGesturePayload{
+		Settings:        e.Payload.Settings,
+		State:           e.Payload.State,
+		IsInMultiAction: e.Payload.IsInMultiAction,
+	}
+	p.Coordinates = e.Payload.Coordinates
+	return p
+}
+
+// after runs fn after d, using one of the timer slots. The caller must hold
+// the lock.
+func (k *keyGesture) after(slot int, d time.Duration, fn func()) {
+	k.timers[slot] = time.AfterFunc(d, fn)
+}
+
+// cancel stops all timers. The caller must hold the lock.
+func (k *keyGesture) cancel() {
+	for i, t := range k.timers {
+		if t != nil {
+			t.Stop()
+			k.timers[i] = nil
+		}
+	}
+	k.seq++
+}
@@ -0,0 +1,121 @@
+package streamdeck
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+func keyDown(context string, multi bool) events.ERKeyDown {
+	e := events.ERKeyDown{ERCommon: events.ERCommon{Context: context, Event: "keyDown"}}
+	e.Payload.IsInMultiAction = multi
+	return e
+}
+
+func keyUp(context string, multi bool) events.ERKeyUp {
+	e := events.ERKeyUp{ERCommon: events.ERCommon{Context: context, Event: "keyUp"}}
+	e.Payload.IsInMultiAction = multi
+	return e
+}
+
+// gestureRecorder registers handlers for every gesture, reporting each as
+// "event context" on a channel.
+func gestureRecorder(c *Connection) chan string {
+	got := make(chan string, 20)
+	c.RegisterHandler(func(e events.KeyShortPress) { got <- e.Event + " " + e.Context })
+	c.RegisterHandler(func(e events.KeyLongPress) { got <- e.Event + " " + e.Context })
+	c.RegisterHandler(func(e events.KeyDoublePress) { got <- e.Event + " " + e.Context })
+	c.RegisterHandler(func(e events.KeyRepeat) { got <- fmt.Sprintf("%s %s %d", e.Event, e.Context, e.Count) })
+	return got
+}
+
+func expectGestures(t *testing.T, got chan string, expected ...string) {
+	t.Helper()
+	for _, exp := range expected {
+		select {
+		case g := <-got:
+			if g != exp {
+				t.Errorf("expected %s, got %s", exp, g)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s", exp)
+		}
+	}
+	select {
+	case g := <-got:
+		t.Errorf("unexpected %s", g)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestKeyGestures(t *testing.T) {
+	c := New()
+	NewKeyGestures(&c, GestureOptions{LongPress: 60 * time.Millisecond, DoublePress: 40 * time.Millisecond})
+	got := gestureRecorder(&c)
+
+	c.handle(keyDown("A", false))
+	c.handle(keyUp("A", false))
+	expectGestures(t, got, "keyShortPress A")
+
+	c.handle(keyDown("A", false))
+	c.handle(keyUp("A", false))
+	c.handle(keyDown("A", false))
+	c.handle(keyUp("A", false))
+	expectGestures(t, got, "keyDoublePress A")
+
+	// the long press is sent while the key is held
+	c.handle(keyDown("A", false))
+	expectGestures(t, got, "keyLongPress A")
+	c.handle(keyUp("A", false))
+	expectGestures(t, got)
+
+	// a short press then a long press is not a double press
+	c.handle(keyDown("B", false))
+	c.handle(keyUp("B", false))
+	c.handle(keyDown("B", false))
+	expectGestures(t, got, "keyShortPress B", "keyLongPress B")
+	c.handle(keyUp("B", false))
+
+	// multi-actions do not wait to see if there is a second press
+	c.handle(keyDown("C", true))
+	c.handle(keyUp("C", true))
+	select {
+	case g := <-got:
+		if g != "keyShortPress C" {
+			t.Errorf("expected a short press, got %s", g)
+		}
+	default:
+		t.Error("expected an immediate short press")
+	}
+
+	// disappearing cancels a pending press
+	c.handle(keyDown("D", false))
+	c.handle(events.ERWillDisappear{ERCommon: events.ERCommon{Context: "D"}})
+	expectGestures(t, got)
+}
+
+func TestKeyRepeat(t *testing.T) {
+	c := New()
+	NewKeyGestures(&c, GestureOptions{LongPress: time.Hour, DoublePress: -1, RepeatDelay: 30 * time.Millisecond, RepeatInterval: 30 * time.Millisecond})
+	got := gestureRecorder(&c)
+
+	c.handle(keyDown("A", false))
+	c.handle(keyUp("A", false))
+	expectGestures(t, got, "keyShortPress A")
+
+	c.handle(keyDown("A", false))
+	for _, exp := range []string{"keyRepeat A 1", "keyRepeat A 2", "keyRepeat A 3"} {
+		if g := <-got; g != exp {
+			t.Errorf("expected %s, got %s", exp, g)
+		}
+	}
+	c.handle(keyUp("A", false))
+	// at most one repeat may have been on its way
+	time.Sleep(50 * time.Millisecond)
+	for len(got) > 0 {
+		<-got
+	}
+	expectGestures(t, got)
+}
@@ -0,0 +1,171 @@
+package layout
+
+import (
+	"image"
+)
+
+// The layouts built in to the Stream Deck application.
+const (
+	LayoutX1 = "$X1" // title and a centred icon
+	LayoutA0 = "$A0" // title and an image filling the canvas
+	LayoutA1 = "$A1" // title, icon and value
+	LayoutB1 = "$B1" // title, icon, value and a bar
+	LayoutB2 = "$B2" // title, icon, value and a gradient bar
+	LayoutC1 = "$C1" // title and two icons with bars
+)
+
+// Item keys used by the built in layouts.
+const (
+	KeyTitle      = "title"
+	KeyIcon       = "icon"
+	KeyValue      = "value"
+	KeyIndicator  = "indicator"
+	KeyFullCanvas = "full-canvas"
+	KeyIcon1      = "icon1"
+	KeyIcon2      = "icon2"
+	KeyIndicator1 = "indicator1"
+	KeyIndicator2 = "indicator2"
+)
+
+// X1 is the feedback for the $X1 layout.
+type X1 struct {
+	Title *Text   `json:"title,omitempty"`
+	Icon  *Pixmap `json:"icon,omitempty"`
+}
+
+func (X1) Layout() string { return LayoutX1 }
+
+func (f X1) validate() error {
+	return validateItems(map[string]interface{ validate() error }{
+		KeyTitle: f.Title,
+		KeyIcon:  f.Icon,
+	})
+}
+
+// A0 is the feedback for the $A0 layout. FullCanvas is drawn behind the
+// title and should be 200x100 pixels.
+type A0 struct {
+	Title      *Text   `json:"title,omitempty"`
+	FullCanvas *Pixmap `json:"full-canvas,omitempty"`
+}
+
+func (A0) Layout() string { return LayoutA0 }
+
+func (f A0) validate() error {
+	return validateItems(map[string]interface{ validate() error }{
+		KeyTitle:      f.Title,
+		KeyFullCanvas: f.FullCanvas,
+	})
+}
+
+// A1 is the feedback for the $A1 layout.
+type A1 struct {
+	Title *Text   `json:"title,omitempty"`
+	Icon  *Pixmap `json:"icon,omitempty"`
+	Value *Text   `json:"value,omitempty"`
+}
+
+func (A1) Layout() string { return LayoutA1 }
+
+func (f A1) validate() error {
+	return validateItems(map[string]interface{ validate() error }{
+		KeyTitle: f.Title,
+		KeyIcon:  f.Icon,
+		KeyValue: f.Value,
+	})
+}
+
+// B1 is the feedback for the $B1 layout.
+type B1 struct {
+	Title     *Text   `json:"title,omitempty"`
+	Icon      *Pixmap `json:"icon,omitempty"`
+	Value     *Text   `json:"value,omitempty"`
+	Indicator *Bar    `json:"indicator,omitempty"`
+}
+
+func (B1) Layout() string { return LayoutB1 }
+
+func (f B1) validate() error {
+	return validateItems(map[string]interface{ validate() error }{
+		KeyTitle:     f.Title,
+		KeyIcon:      f.Icon,
+		KeyValue:     f.Value,
+		KeyIndicator: f.Indicator,
+	})
+}
+
+// B2 is the feedback for the $B2 layout, where the indicator is a gbar.
+type B2 struct {
+	Title     *Text   `json:"title,omitempty"`
+	Icon      *Pixmap `json:"icon,omitempty"`
+	Value     *Text   `json:"value,omitempty"`
+	Indicator *Bar    `json:"indicator,omitempty"`
+}
+
+func (B2) Layout() string { return LayoutB2 }
+
+func (f B2) validate() error {
+	return B1(f).validate()
+}
+
+// C1 is the feedback for the $C1 layout.
+type C1 struct {
+	Title      *Text   `json:"title,omitempty"`
+	Icon1      *Pixmap `json:"icon1,omitempty"`
+	Icon2      *Pixmap `json:"icon2,omitempty"`
+	Indicator1 *Bar    `json:"indicator1,omitempty"`
+	Indicator2 *Bar    `json:"indicator2,omitempty"`
+}
+
+func (C1) Layout() string { return LayoutC1 }
+
+func (f C1) validate() error {
+	return validateItems(map[string]interface{ validate() error }{
+		KeyTitle:      f.Title,
+		KeyIcon1:      f.Icon1,
+		KeyIcon2:      f.Icon2,
+		KeyIndicator1: f.Indicator1,
+		KeyIndicator2: f.Indicator2,
+	})
+}
+
+// titleRect is where the title of every built in layout is.
+var titleRect = image.Rect(16, 10, 152, 34)
+
+// builtins describes the items of the built in layouts, as given in the
+// Stream Deck SDK documentation, so that taps can be matched to items.
+var builtins = map[string]*Custom{
+	LayoutX1: NewCustom(LayoutX1, LayoutX1).
+		Text(KeyTitle, titleRect, nil).
+		Pixmap(KeyIcon, image.Rect(76, 40, 124, 88), nil),
+	LayoutA0: NewCustom(LayoutA0, LayoutA0).
+		Pixmap(KeyFullCanvas, image.Rect(0, 0, 200, 100), nil).
+		Add(Item{Key: KeyTitle, Type: ItemText, Rect: titleRect, ZOrder: 1}),
+	LayoutA1: NewCustom(LayoutA1, LayoutA1).
+		Text(KeyTitle, titleRect, nil).
+		Pixmap(KeyIcon, image.Rect(16, 40, 64, 88), nil).
+		Text(KeyValue, image.Rect(76, 40, 184, 72), nil),
+	LayoutB1: NewCustom(LayoutB1, LayoutB1).
+		Text(KeyTitle, titleRect, nil).
+		Pixmap(KeyIcon, image.Rect(16, 40, 64, 88), nil).
+		Text(KeyValue, image.Rect(76, 40, 184, 72), nil).
+		Bar(KeyIndicator, image.Rect(76, 74, 184, 94), nil),
+	LayoutB2: NewCustom(LayoutB2, LayoutB2).
+		Text(KeyTitle, titleRect, nil).
+		Pixmap(KeyIcon, image.Rect(16, 40, 64, 88), nil).
+		Text(KeyValue, image.Rect(76, 40, 184, 72), nil).
+		GBar(KeyIndicator, image.Rect(76, 74, 184, 94), nil),
+	LayoutC1: NewCustom(LayoutC1, LayoutC1).
+		Text(KeyTitle, titleRect, nil).
+		Pixmap(KeyIcon1, image.Rect(16, 40, 40, 64), nil).
+		Bar(KeyIndicator1, image.Rect(48, 42, 184, 62), nil).
+		Pixmap(KeyIcon2, image.Rect(16, 70, 40, 94), nil).
+		Bar(KeyIndicator2, image.Rect(48, 72, 184, 92), nil),
+}
+
+// Builtin returns the items of one of the built in layouts, such as
+// LayoutB1. It should not be modified.
+func Builtin(name string) (*Custom, bool) {
+	c, ok := builtins[name]
+	return c, ok
+}
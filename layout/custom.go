@@ -0,0 +1,329 @@
+package layout
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/tardisx/streamdeck-plugin/tools"
+)
+
+// ItemType is the kind of an item in a custom layout.
+type ItemType string
+
+const (
+	ItemText   = ItemType("text")
+	ItemBar    = ItemType("bar")
+	ItemGBar   = ItemType("gbar")
+	ItemPixmap = ItemType("pixmap")
+)
+
+// MaxZOrder is the highest z-order an item may have. Items with higher
+// z-orders are drawn on top, and items with the same z-order must not
+// overlap.
+const MaxZOrder = 700
+
+// Item is an item in a custom layout.
+type Item struct {
+	Key  string
+	Type ItemType
+	// Rect is the area of the item on the 200x100 canvas.
+	Rect   image.Rectangle
+	ZOrder int
+	// Default holds the initial value and settings of the item, and must be
+	// a *Text, *Bar or *Pixmap to match Type. It may be nil.
+	Default any
+}
+
+// MarshalJSON encodes the item as in a layout file, with the rectangle as
+// x, y, width and height and the defaults alongside.
+func (it Item) MarshalJSON() ([]byte, error) {
+	head, err := json.Marshal(struct {
+		Key    string   `json:"key"`
+		Type   ItemType `json:"type"`
+		Rect   [4]int   `json:"rect"`
+		ZOrder int      `json:"zOrder,omitempty"`
+	}{
+		Key:    it.Key,
+		Type:   it.Type,
+		Rect:   [4]int{it.Rect.Min.X, it.Rect.Min.Y, it.Rect.Dx(), it.Rect.Dy()},
+		ZOrder: it.ZOrder,
+	})
+	if err != nil || it.Default == nil {
+		return head, err
+	}
+	defaults, err := json.Marshal(it.Default)
+	if err != nil {
+		return nil, err
+	}
+	if len(defaults) <= 2 {
+		return head, nil
+	}
+	// splice the two objects together
+	head = append(head[:len(head)-1], ',')
+	return append(head, defaults[1:]...), nil
+}
+
+func (it Item) validate() error {
+	if it.Key == "" {
+		return errors.New("item has no key")
+	}
+	var ok bool
+	switch it.Type {
+	case ItemText:
+		_, ok = it.Default.(*Text)
+	case ItemBar, ItemGBar:
+		_, ok = it.Default.(*Bar)
+	case ItemPixmap:
+		_, ok = it.Default.(*Pixmap)
+	default:
+		return fmt.Errorf("%s: unknown item type %q", it.Key, it.Type)
+	}
+	if !ok && it.Default != nil {
+		return fmt.Errorf("%s: default %T does not suit a %s item", it.Key, it.Default, it.Type)
+	}
+	if it.Rect.Empty() {
+		return fmt.Errorf("%s: rect is empty", it.Key)
+	}
+	canvas := image.Rectangle{Max: tools.SegmentSize}
+	if !it.Rect.In(canvas) {
+		return fmt.Errorf("%s: rect %v is outside the %v canvas", it.Key, it.Rect, canvas.Size())
+	}
+	if it.ZOrder < 0 || it.ZOrder > MaxZOrder {
+		return fmt.Errorf("%s: z-order %d is not between 0 and %d", it.Key, it.ZOrder, MaxZOrder)
+	}
+	if v, ok := it.Default.(interface{ validate() error }); ok {
+		err := v.validate()
+		if err != nil {
+			return fmt.Errorf("%s: %w", it.Key, err)
+		}
+	}
+	return nil
+}
+
+// Custom is a custom touch strip layout, as kept in a JSON file in the
+// plugin bundle.
+type Custom struct {
+	// ID identifies the layout.
+	ID string
+	// Path is where the layout file is in the plugin bundle, relative to the
+	// manifest. It is the name used with events.NewESSetFeedbackLayout.
+	Path  string
+	Items []Item
+}
+
+// NewCustom starts a custom layout.
+func NewCustom(id string, path string) *Custom {
+	return &Custom{ID: id, Path: path}
+}
+
+// Layout returns the path of the layout, as used with
+// events.NewESSetFeedbackLayout.
+func (c *Custom) Layout() string {
+	return c.Path
+}
+
+// Add adds items to the layout.
+func (c *Custom) Add(items ...Item) *Custom {
+	c.Items = append(c.Items, items...)
+	return c
+}
+
+// Text adds a text item, with optional defaults.
+func (c *Custom) Text(key string, rect image.Rectangle, defaults *Text) *Custom {
+	return c.Add(Item{Key: key, Type: ItemText, Rect: rect, Default: nilIfEmpty(defaults)})
+}
+
+// Bar adds a bar item, with optional defaults.
+func (c *Custom) Bar(key string, rect image.Rectangle, defaults *Bar) *Custom {
+	return c.Add(Item{Key: key, Type: ItemBar, Rect: rect, Default: nilIfEmpty(defaults)})
+}
+
+// GBar adds a gbar item, a bar drawn with a gradient, with optional defaults.
+func (c *Custom) GBar(key string, rect image.Rectangle, defaults *Bar) *Custom {
+	return c.Add(Item{Key: key, Type: ItemGBar, Rect: rect, Default: nilIfEmpty(defaults)})
+}
+
+// Pixmap adds an image item, with optional defaults.
+func (c *Custom) Pixmap(key string, rect image.Rectangle, defaults *Pixmap) *Custom {
+	return c.Add(Item{Key: key, Type: ItemPixmap, Rect: rect, Default: nilIfEmpty(defaults)})
+}
+
+// nilIfEmpty keeps a typed nil pointer out of Item.Default, so that it can be
+// compared with nil.
+func nilIfEmpty[T any](v *T) any {
+	if v == nil {
+		return nil
+	}
+	return v
+}
+
+// Item returns the item with the given key.
+func (c *Custom) Item(key string) (Item, bool) {
+	for _, it := range c.Items {
+		if it.Key == key {
+			return it, true
+		}
+	}
+	return Item{}, false
+}
+
+// HitTest returns the item at a point on the canvas, such as the tap
+// position of an events.ERTouchTap, and the point relative to the item's top
+// left corner. Where items overlap the one drawn on top is returned.
+func (c *Custom) HitTest(p image.Point) (Item, image.Point, bool) {
+	found := -1
+	for i, it := range c.Items {
+		if p.In(it.Rect) && (found < 0 || it.ZOrder >= c.Items[found].ZOrder) {
+			found = i
+		}
+	}
+	if found < 0 {
+		return Item{}, image.Point{}, false
+	}
+	it := c.Items[found]
+	return it, p.Sub(it.Rect.Min), true
+}
+
+// Validate checks that the layout will be accepted by the Stream Deck
+// application: every item has a unique key, lies within the canvas, does not
+// overlap another item with the same z-order, and has sensible defaults.
+func (c *Custom) Validate() error {
+	if c.ID == "" {
+		return errors.New("layout has no id")
+	}
+	if len(c.Items) == 0 {
+		return errors.New("layout has no items")
+	}
+	seen := map[string]bool{}
+	for i, it := range c.Items {
+		err := it.validate()
+		if err != nil {
+			return err
+		}
+		if seen[it.Key] {
+			return fmt.Errorf("%s: duplicate key", it.Key)
+		}
+		seen[it.Key] = true
+		for _, other := range c.Items[:i] {
+			if other.ZOrder == it.ZOrder && other.Rect.Overlaps(it.Rect) {
+				return fmt.Errorf("%s: overlaps %s, give one a higher z-order", it.Key, other.Key)
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes the layout file.
+func (c *Custom) MarshalJSON() ([]byte, error) {
+	items := c.Items
+	if items == nil {
+		items = []Item{}
+	}
+	return json.Marshal(struct {
+		ID    string `json:"id"`
+		Items []Item `json:"items"`
+	}{c.ID, items})
+}
+
+// WriteFile validates the layout and writes the layout file to path, which
+// is usually under the plugin bundle at c.Path.
+func (c *Custom) WriteFile(path string) error {
+	err := c.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid layout %s: %w", c.ID, err)
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	out := bytes.Buffer{}
+	json.Indent(&out, b, "", "  ")
+	out.WriteByte('\n')
+	return os.WriteFile(path, out.Bytes(), 0o644)
+}
+
+// Feedback starts the feedback for the layout. Only items declared in the
+// layout may be set, with values suiting their type.
+func (c *Custom) Feedback() *CustomFeedback {
+	return &CustomFeedback{layout: c, items: map[string]interface{ validate() error }{}}
+}
+
+// CustomFeedback is feedback for a custom layout, built by setting items in
+// turn. The first mistake, such as a key not in the layout, is kept and
+// returned by Payload or NewFeedback.
+type CustomFeedback struct {
+	layout *Custom
+	items  map[string]interface{ validate() error }
+	err    error
+}
+
+// Layout returns the path of the layout the feedback is for.
+func (f *CustomFeedback) Layout() string {
+	return f.layout.Layout()
+}
+
+// Text sets a text item.
+func (f *CustomFeedback) Text(key string, t Text) *CustomFeedback {
+	return f.set(key, &t, ItemText)
+}
+
+// Bar sets a bar or gbar item. If no range is given the value is checked
+// against the range in the layout.
+func (f *CustomFeedback) Bar(key string, b Bar) *CustomFeedback {
+	r := defaultRange
+	if it, ok := f.layout.Item(key); ok {
+		if d, ok := it.Default.(*Bar); ok && d.Range != nil {
+			r = *d.Range
+		}
+	}
+	return f.set(key, rangedBar{Bar: &b, r: r}, ItemBar, ItemGBar)
+}
+
+// rangedBar is a bar checked against the range of its item in the layout.
+type rangedBar struct {
+	*Bar
+	r Range
+}
+
+func (b rangedBar) validate() error {
+	return b.validateIn(b.r)
+}
+
+// Pixmap sets an image item.
+func (f *CustomFeedback) Pixmap(key string, p Pixmap) *CustomFeedback {
+	return f.set(key, &p, ItemPixmap)
+}
+
+func (f *CustomFeedback) set(key string, v interface{ validate() error }, types ...ItemType) *CustomFeedback {
+	if f.err != nil {
+		return f
+	}
+	it, ok := f.layout.Item(key)
+	if !ok {
+		f.err = fmt.Errorf("%s: no such item in layout %s", key, f.layout.ID)
+		return f
+	}
+	for _, t := range types {
+		if it.Type == t {
+			f.items[key] = v
+			return f
+		}
+	}
+	f.err = fmt.Errorf("%s: item is a %s, not a %s", key, it.Type, types[0])
+	return f
+}
+
+func (f *CustomFeedback) validate() error {
+	if f.err != nil {
+		return f.err
+	}
+	return validateItems(f.items)
+}
+
+func (f *CustomFeedback) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.items)
+}
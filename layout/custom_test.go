@@ -0,0 +1,97 @@
+package layout
+
+import (
+	"encoding/json"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func volumeLayout() *Custom {
+	return NewCustom("com.example.volume", "layouts/volume.json").
+		Text("label", image.Rect(10, 5, 190, 30), &Text{Value: "Volume", Alignment: AlignLeft}).
+		Bar("level", image.Rect(10, 40, 190, 60), &Bar{Range: &Range{Min: 0, Max: 11}}).
+		Pixmap("icon", image.Rect(0, 0, 200, 100), nil)
+}
+
+func TestCustomValidate(t *testing.T) {
+	l := volumeLayout()
+	l.Items[2].ZOrder = 1
+	err := l.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, bad := range map[string]*Custom{
+		"overlap":   volumeLayout(),
+		"bounds":    NewCustom("x", "x.json").Text("t", image.Rect(150, 0, 210, 20), nil),
+		"duplicate": NewCustom("x", "x.json").Text("t", image.Rect(0, 0, 10, 10), nil).Text("t", image.Rect(20, 0, 30, 10), nil),
+		"no key":    NewCustom("x", "x.json").Text("", image.Rect(0, 0, 10, 10), nil),
+		"range":     NewCustom("x", "x.json").Bar("b", image.Rect(0, 0, 10, 10), &Bar{Value: 20, Range: &Range{Max: 10}}),
+		"type":      NewCustom("x", "x.json").Add(Item{Key: "b", Type: ItemBar, Rect: image.Rect(0, 0, 10, 10), Default: &Text{}}),
+		"empty":     NewCustom("x", "x.json"),
+	} {
+		if bad.Validate() == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCustomWriteFile(t *testing.T) {
+	l := volumeLayout()
+	l.Items[2].ZOrder = 1
+	path := filepath.Join(t.TempDir(), "volume.json")
+	err := l.WriteFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(path)
+	var file struct {
+		ID    string
+		Items []map[string]any
+	}
+	err = json.Unmarshal(b, &file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.ID != "com.example.volume" || len(file.Items) != 3 {
+		t.Fatalf("wrong file %s", b)
+	}
+	label := file.Items[0]
+	if label["key"] != "label" || label["type"] != "text" || label["value"] != "Volume" || label["alignment"] != "left" {
+		t.Errorf("wrong label %v", label)
+	}
+	if r, _ := json.Marshal(label["rect"]); string(r) != "[10,5,180,25]" {
+		t.Errorf("wrong rect %s", r)
+	}
+	if file.Items[2]["zOrder"] != 1.0 {
+		t.Errorf("wrong icon %v", file.Items[2])
+	}
+
+	if volumeLayout().WriteFile(path) == nil {
+		t.Error("expected an invalid layout not to be written")
+	}
+}
+
+func TestCustomFeedback(t *testing.T) {
+	l := volumeLayout()
+	fb, err := NewFeedback("ctx", l.Feedback().Text("label", Text{Value: "Mute"}).Bar("level", Bar{Value: 11}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(fb.Payload) != `{"label":{"value":"Mute"},"level":{"value":11}}` {
+		t.Errorf("wrong payload %s", fb.Payload)
+	}
+
+	for name, bad := range map[string]*CustomFeedback{
+		"unknown key": l.Feedback().Text("title", Text{}),
+		"wrong type":  l.Feedback().Bar("label", Bar{}),
+		"range":       l.Feedback().Bar("level", Bar{Value: 12}),
+	} {
+		_, err := Payload(bad)
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
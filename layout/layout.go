@@ -0,0 +1,224 @@
+// Package layout provides typed feedback for the touch strip layouts of the
+// Stream Deck+.
+//
+// Each of the layouts built in to the Stream Deck application has a struct
+// with a field for each of its items. Set the fields to change and leave the
+// rest nil, then use NewFeedback to build the setFeedback message:
+//
+//	fb, err := layout.NewFeedback(context, layout.B1{
+//		Value:     &layout.Text{Value: "75%"},
+//		Indicator: &layout.Bar{Value: 75},
+//	})
+//
+// The layout must first be chosen in the manifest or with
+// events.NewESSetFeedbackLayout.
+//
+// Custom layouts are built with NewCustom, checked with Custom.Validate and
+// written into the plugin bundle with Custom.WriteFile. Their feedback is
+// built with Custom.Feedback, which only allows the items the layout has.
+package layout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+// Alignment is the horizontal alignment of a text item.
+type Alignment string
+
+const (
+	AlignLeft   = Alignment("left")
+	AlignCenter = Alignment("center")
+	AlignRight  = Alignment("right")
+)
+
+// Options are the settings common to every kind of item. Nil pointers leave
+// the setting as it is.
+type Options struct {
+	// Opacity is from 0, invisible, to 1.
+	Opacity *float64 `json:"opacity,omitempty"`
+	// Enabled hides the item when false.
+	Enabled *bool `json:"enabled,omitempty"`
+	// Background is a colour, gradient or image path drawn behind the item.
+	Background string `json:"background,omitempty"`
+}
+
+// Font sets the size and weight of a text item.
+type Font struct {
+	Size   int `json:"size,omitempty"`
+	Weight int `json:"weight,omitempty"` // 100 to 1000
+}
+
+// Text sets a text item. The value is always sent, so an empty Value clears
+// the text.
+type Text struct {
+	Value     string    `json:"value"`
+	Color     string    `json:"color,omitempty"`
+	Alignment Alignment `json:"alignment,omitempty"`
+	Font      *Font     `json:"font,omitempty"`
+	Options
+}
+
+// Pixmap sets an image item. Value is a data URL, such as from tools.Encode,
+// or a path to an image in the plugin bundle. An empty Value leaves the image
+// as it is, so that only the options are changed.
+type Pixmap struct {
+	Value string `json:"value,omitempty"`
+	Options
+}
+
+// Range is the range of values of a bar.
+type Range struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// Bar sets a bar or gbar item. The value is always sent, and is within the
+// Range, which is 0 to 100 when not set.
+type Bar struct {
+	Value float64 `json:"value"`
+	Range *Range  `json:"range,omitempty"`
+	// Colours of the bar. For a gbar FillColor may be a gradient, see
+	// Gradient.
+	BackgroundColor string `json:"bar_bg_c,omitempty"`
+	FillColor       string `json:"bar_fill_c,omitempty"`
+	BorderColor     string `json:"bar_border_c,omitempty"`
+	// BorderWidth is in pixels, a pointer so that it can be set to zero.
+	BorderWidth *int `json:"border_w,omitempty"`
+	// Height is the height of the bar in pixels.
+	Height int `json:"bar_h,omitempty"`
+	Options
+}
+
+// GradientStop is a colour at a position, from 0 to 1, along a gradient.
+type GradientStop struct {
+	Position float64
+	Color    string
+}
+
+// Gradient builds a gradient for Bar.FillColor, such as
+// "0:#ff0000,1:#00ff00".
+func Gradient(stops ...GradientStop) string {
+	s := ""
+	for i, stop := range stops {
+		if i > 0 {
+			s += ","
+		}
+		s += fmt.Sprintf("%g:%s", stop.Position, stop.Color)
+	}
+	return s
+}
+
+// Feedback is the typed feedback for one of the layouts.
+type Feedback interface {
+	// Layout is the name of the layout, as used with
+	// events.NewESSetFeedbackLayout.
+	Layout() string
+}
+
+// Payload checks the feedback and returns it as the payload of a
+// setFeedback message.
+func Payload(f Feedback) (json.RawMessage, error) {
+	if v, ok := f.(interface{ validate() error }); ok {
+		err := v.validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid feedback for %s: %w", f.Layout(), err)
+		}
+	}
+	return json.Marshal(f)
+}
+
+// NewFeedback builds the setFeedback message for a context from typed
+// feedback.
+func NewFeedback(context string, f Feedback) (events.ESSetFeedback, error) {
+	payload, err := Payload(f)
+	if err != nil {
+		return events.ESSetFeedback{}, err
+	}
+	return events.NewESSetFeedback(context, payload), nil
+}
+
+var colorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+func validColor(c string) bool {
+	return c == "" || colorPattern.MatchString(c)
+}
+
+func (o Options) validate() error {
+	if o.Opacity != nil && (*o.Opacity < 0 || *o.Opacity > 1) {
+		return fmt.Errorf("opacity %g is not between 0 and 1", *o.Opacity)
+	}
+	return nil
+}
+
+func (t *Text) validate() error {
+	if t == nil {
+		return nil
+	}
+	if !validColor(t.Color) {
+		return fmt.Errorf("invalid colour %q", t.Color)
+	}
+	switch t.Alignment {
+	case "", AlignLeft, AlignCenter, AlignRight:
+	default:
+		return fmt.Errorf("invalid alignment %q", t.Alignment)
+	}
+	if t.Font != nil && t.Font.Weight != 0 && (t.Font.Weight < 100 || t.Font.Weight > 1000) {
+		return fmt.Errorf("font weight %d is not between 100 and 1000", t.Font.Weight)
+	}
+	return t.Options.validate()
+}
+
+func (p *Pixmap) validate() error {
+	if p == nil {
+		return nil
+	}
+	return p.Options.validate()
+}
+
+// defaultRange is the range of a bar when none is set.
+var defaultRange = Range{Min: 0, Max: 100}
+
+func (b *Bar) validate() error {
+	return b.validateIn(defaultRange)
+}
+
+// validateIn checks the bar, with r as the range if the bar has none.
+func (b *Bar) validateIn(r Range) error {
+	if b == nil {
+		return nil
+	}
+	if b.Range != nil {
+		r = *b.Range
+	}
+	if r.Min >= r.Max {
+		return fmt.Errorf("range %g to %g is empty", r.Min, r.Max)
+	}
+	if b.Value < r.Min || b.Value > r.Max {
+		return fmt.Errorf("value %g is outside the range %g to %g", b.Value, r.Min, r.Max)
+	}
+	for _, c := range []string{b.BackgroundColor, b.BorderColor} {
+		if !validColor(c) {
+			return fmt.Errorf("invalid colour %q", c)
+		}
+	}
+	if b.BorderWidth != nil && *b.BorderWidth < 0 {
+		return errors.New("border width is negative")
+	}
+	return b.Options.validate()
+}
+
+// validateItems checks each item, naming the item key in any error.
+func validateItems(items map[string]interface{ validate() error }) error {
+	for key, item := range items {
+		err := item.validate()
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package layout
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewFeedback(t *testing.T) {
+	hidden := false
+	fb, err := NewFeedback("ctx", B1{
+		Title:     &Text{Value: "Volume", Color: "#ff0000", Font: &Font{Size: 16, Weight: 600}},
+		Icon:      &Pixmap{Options: Options{Enabled: &hidden}},
+		Indicator: &Bar{Value: 3, Range: &Range{Min: 0, Max: 10}, FillColor: Gradient(GradientStop{0, "#00ff00"}, GradientStop{1, "#ff0000"})},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fb.Event != "setFeedback" || fb.Context != "ctx" {
+		t.Errorf("wrong message %+v", fb)
+	}
+	expected := `{"title":{"value":"Volume","color":"#ff0000","font":{"size":16,"weight":600}},"icon":{"enabled":false},"indicator":{"value":3,"range":{"min":0,"max":10},"bar_fill_c":"0:#00ff00,1:#ff0000"}}`
+	if string(fb.Payload) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, fb.Payload)
+	}
+
+	// the value of a bar is always sent, even when zero
+	p, err := Payload(C1{Indicator2: &Bar{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]map[string]any
+	json.Unmarshal(p, &m)
+	if v, ok := m[KeyIndicator2]["value"]; !ok || v != 0.0 {
+		t.Errorf("expected a zero value, got %s", p)
+	}
+
+	opacity := 1.5
+	for _, bad := range []Feedback{
+		A1{Value: &Text{Color: "red"}},
+		X1{Title: &Text{Alignment: "middle"}},
+		B2{Indicator: &Bar{Value: 120}},
+		C1{Indicator1: &Bar{Range: &Range{Min: 5, Max: 5}, Value: 5}},
+		A0{FullCanvas: &Pixmap{Options: Options{Opacity: &opacity}}},
+	} {
+		_, err := Payload(bad)
+		if err == nil {
+			t.Errorf("expected an error for %+v", bad)
+		}
+	}
+}
+
+func TestBuiltinLayouts(t *testing.T) {
+	for _, name := range []string{LayoutX1, LayoutA0, LayoutA1, LayoutB1, LayoutB2, LayoutC1} {
+		l, ok := Builtin(name)
+		if !ok {
+			t.Errorf("%s is missing", name)
+			continue
+		}
+		err := l.Validate()
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package streamdeck
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Direction values used in recorded frames.
+const (
+	DirectionIn  = "in"
+	DirectionOut = "out"
+)
+
+// redactedValue replaces the value of any settings field the redaction
+// hook matches.
+const redactedValue = "[REDACTED]"
+
+// RecordedFrame is a single line of a recording. Data holds the frame exactly
+// as it went over the wire, apart from any redacted settings fields.
+type RecordedFrame struct {
+	Time      time.Time       `json:"time"`
+	Direction string          `json:"direction"` // DirectionIn or DirectionOut
+	Event     string          `json:"event"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// RecorderOptions configures a Recorder.
+type RecorderOptions struct {
+	// MaxBytes is the size a recording file may reach before it is rotated.
+	// Zero means the file is never rotated.
+	MaxBytes int64
+	// MaxFiles is the number of rotated files to keep alongside the active
+	// one. Rotated files are named path.1, path.2 and so on, with .1 being
+	// the most recent. Zero means rotated files are discarded.
+	MaxFiles int
+	// Redact is called with the name of every field found inside a settings
+	// object. If it returns true, the value is replaced before the frame is
+	// written. See RedactKeys for a simple implementation.
+	Redact func(key string) bool
+}
+
+// Recorder writes protocol traffic to a JSON Lines file, one RecordedFrame
+// per line. Attach it to a Connection with SetRecorder.
+type Recorder struct {
+	mu   sync.Mutex
+	path string
+	opts RecorderOptions
+	f    *os.File
+	size int64
+}
+
+// NewRecorder creates a Recorder writing to path. An existing file at path
+// is appended to.
+func NewRecorder(path string, opts RecorderOptions) (*Recorder, error) {
+	r := &Recorder{
+		path: path,
+		opts: opts,
+	}
+	err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// RedactKeys returns a redaction hook for RecorderOptions that matches any of
+// the given field names.
+func RedactKeys(keys ...string) func(string) bool {
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		set[k] = true
+	}
+	return func(key string) bool {
+		return set[key]
+	}
+}
+
+// Record writes a single frame. The event name is taken from the frame
+// itself. Errors are returned but the connection does not act on them, a
+// broken recording should never take down the plugin.
+func (r *Recorder) Record(direction string, frame []byte) error {
+	base := struct {
+		Event string `json:"event"`
+	}{}
+	// frames we can't decode are still worth recording
+	_ = json.Unmarshal(frame, &base)
+
+	data := json.RawMessage(frame)
+	if r.opts.Redact != nil {
+		data = r.redact(base.Event, frame)
+	}
+	if !json.Valid(data) {
+		// keep the line valid JSON, even if the frame was not
+		data, _ = json.Marshal(string(frame))
+	}
+
+	line, err := json.Marshal(RecordedFrame{
+		Time:      time.Now(),
+		Direction: direction,
+		Event:     base.Event,
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		return fmt.Errorf("recorder is closed")
+	}
+	if r.opts.MaxBytes > 0 && r.size > 0 && r.size+int64(len(line)) > r.opts.MaxBytes {
+		err = r.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	n, err := r.f.Write(line)
+	r.size += int64(n)
+	return err
+}
+
+// Close closes the underlying file. Frames recorded after Close are dropped
+// with an error.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+func (r *Recorder) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = st.Size()
+	return nil
+}
+
+// rotate shifts path.N to path.N+1, dropping anything beyond MaxFiles, then
+// starts a fresh file at path. The caller must hold the lock.
+func (r *Recorder) rotate() error {
+	err := r.f.Close()
+	if err != nil {
+		return err
+	}
+	r.f = nil
+
+	if r.opts.MaxFiles < 1 {
+		err = os.Remove(r.path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return r.open()
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", r.path, r.opts.MaxFiles))
+	for i := r.opts.MaxFiles - 1; i >= 1; i-- {
+		err = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	err = os.Rename(r.path, r.path+".1")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return r.open()
+}
+
+// redact returns a copy of the frame with matching settings fields replaced.
+// Received events carry settings in payload.settings, while setSettings and
+// setGlobalSettings use the payload itself.
+func (r *Recorder) redact(event string, frame []byte) json.RawMessage {
+	var msg map[string]any
+	err := json.Unmarshal(frame, &msg)
+	if err != nil {
+		return frame
+	}
+
+	changed := false
+	switch event {
+	case "setSettings", "setGlobalSettings":
+		for _, k := range []string{"payload", "Payload"} {
+			if p, ok := msg[k]; ok {
+				changed = r.redactValue(p) || changed
+			}
+		}
+	default:
+		if p, ok := msg["payload"].(map[string]any); ok {
+			if s, ok := p["settings"]; ok {
+				changed = r.redactValue(s)
+			}
+		}
+	}
+	if !changed {
+		return frame
+	}
+
+	out, err := json.Marshal(msg)
+	if err != nil {
+		return frame
+	}
+	return out
+}
+
+// redactValue walks v, replacing any matching object fields. It reports
+// whether anything was replaced.
+func (r *Recorder) redactValue(v any) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]any:
+		for k, sub := range v {
+			if r.opts.Redact(k) {
+				v[k] = redactedValue
+				changed = true
+				continue
+			}
+			changed = r.redactValue(sub) || changed
+		}
+	case []any:
+		for _, sub := range v {
+			changed = r.redactValue(sub) || changed
+		}
+	}
+	return changed
+}
@@ -0,0 +1,93 @@
+package streamdeck
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+func TestRecorderRedact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	r, err := NewRecorder(path, RecorderOptions{Redact: RedactKeys("apiKey")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := []byte(`{"event":"keyUp","context":"ABC123","payload":{"settings":{"apiKey":"hunter2","nested":{"apiKey":"hunter3"},"name":"bob"}}}`)
+	err = r.Record(DirectionIn, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := json.Marshal(events.NewESSetSettings("ABC123", json.RawMessage(`{"apiKey":"hunter2"}`)))
+	err = r.Record(DirectionOut, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "hunter") {
+		t.Errorf("secret was not redacted:\n%s", b)
+	}
+	if !strings.Contains(string(b), "bob") {
+		t.Errorf("non-secret was redacted:\n%s", b)
+	}
+
+	frames := []RecordedFrame{}
+	s := bufio.NewScanner(strings.NewReader(string(b)))
+	for s.Scan() {
+		f := RecordedFrame{}
+		err := json.Unmarshal(s.Bytes(), &f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, f)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(frames))
+	}
+	if frames[0].Direction != DirectionIn || frames[0].Event != "keyUp" {
+		t.Errorf("wrong first frame: %+v", frames[0])
+	}
+	if frames[1].Direction != DirectionOut || frames[1].Event != "setSettings" {
+		t.Errorf("wrong second frame: %+v", frames[1])
+	}
+}
+
+func TestRecorderRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	r, err := NewRecorder(path, RecorderOptions{MaxBytes: 200, MaxFiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for i := 0; i < 20; i++ {
+		err = r.Record(DirectionIn, []byte(`{"event":"keyDown","context":"ABC123"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		st, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %s", p, err)
+		}
+		if st.Size() > 200 {
+			t.Errorf("%s is %d bytes, over the cap", p, st.Size())
+		}
+	}
+	_, err = os.Stat(path + ".3")
+	if !os.IsNotExist(err) {
+		t.Errorf("expected only 2 rotated files")
+	}
+}
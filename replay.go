@@ -0,0 +1,276 @@
+package streamdeck
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// defaultSettle is how long Replay waits for the plugin to finish sending
+// after the last inbound frame, if ReplayOptions.Settle is not set.
+const defaultSettle = 500 * time.Millisecond
+
+// ReplayOptions configures Replay.
+type ReplayOptions struct {
+	// Speed scales the gaps between inbound frames. 1 replays at the
+	// original timing, 2 twice as fast and so on. Zero (or less) sends
+	// frames as fast as possible.
+	Speed float64
+	// Settle is how long to wait after the last inbound frame for any
+	// remaining outbound messages. Defaults to 500ms.
+	Settle time.Duration
+	// InMemory connects the plugin through a pipe transport rather than a
+	// local websocket server. Any transport already set on the connection
+	// is replaced.
+	InMemory bool
+}
+
+// Divergence describes an outbound message that did not match the recording.
+// Expected is nil if the plugin sent more messages than were recorded, and
+// Actual is nil if it sent fewer.
+type Divergence struct {
+	Index    int // position among the outbound messages
+	Expected json.RawMessage
+	Actual   json.RawMessage
+}
+
+func (d Divergence) String() string {
+	switch {
+	case d.Expected == nil:
+		return fmt.Sprintf("message %d: unexpected %s", d.Index, d.Actual)
+	case d.Actual == nil:
+		return fmt.Sprintf("message %d: missing %s", d.Index, d.Expected)
+	default:
+		return fmt.Sprintf("message %d: expected %s, got %s", d.Index, d.Expected, d.Actual)
+	}
+}
+
+// ReplayReport is the result of a Replay.
+type ReplayReport struct {
+	Inbound     int               // number of inbound frames fed to the plugin
+	Outbound    []json.RawMessage // messages the plugin sent, in order
+	Divergences []Divergence
+}
+
+// OK returns true if the plugin sent exactly what was recorded.
+func (r ReplayReport) OK() bool {
+	return len(r.Divergences) == 0
+}
+
+// LoadRecording reads a JSON Lines file written by a Recorder. Rotated files
+// are not followed, load each one separately.
+func LoadRecording(path string) ([]RecordedFrame, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	frames := []RecordedFrame{}
+	s := bufio.NewScanner(f)
+	// images make for long lines
+	s.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for s.Scan() {
+		line++
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		frame := RecordedFrame{}
+		err := json.Unmarshal(s.Bytes(), &frame)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		frames = append(frames, frame)
+	}
+	return frames, s.Err()
+}
+
+// Replay connects conn to a local websocket server (or an in-memory pipe)
+// which plays back the inbound frames of a recording, then compares the
+// messages the plugin sends against the recorded outbound ones. Handlers
+// should be registered on conn before calling Replay, exactly as they would
+// be for Connect. Replay blocks until the plugin has exited.
+//
+// Recorded values that were redacted match any value.
+func Replay(conn *Connection, frames []RecordedFrame, opts ReplayOptions) (ReplayReport, error) {
+	if opts.Settle == 0 {
+		opts.Settle = defaultSettle
+	}
+
+	report := ReplayReport{}
+	outMu := sync.Mutex{}
+	finished := make(chan error, 1)
+
+	// host plays the part of the Stream Deck application
+	host := func(t Transport) {
+		defer t.Close()
+
+		// the first message is always the registration
+		_, err := t.ReadFrame()
+		if err != nil {
+			finished <- fmt.Errorf("reading registration: %w", err)
+			return
+		}
+
+		readDone := make(chan bool)
+		go func() {
+			defer close(readDone)
+			for {
+				b, err := t.ReadFrame()
+				if err != nil {
+					return
+				}
+				outMu.Lock()
+				report.Outbound = append(report.Outbound, b)
+				outMu.Unlock()
+			}
+		}()
+
+		var last time.Time
+		for _, f := range frames {
+			if f.Direction != DirectionIn {
+				continue
+			}
+			if opts.Speed > 0 && !last.IsZero() {
+				time.Sleep(time.Duration(float64(f.Time.Sub(last)) / opts.Speed))
+			}
+			last = f.Time
+			err := t.WriteFrame(f.Data)
+			if err != nil {
+				finished <- err
+				return
+			}
+			report.Inbound++
+		}
+
+		time.Sleep(opts.Settle)
+		t.Close()
+		// once the transport is closed everything the plugin sent has
+		// been read
+		select {
+		case <-readDone:
+		case <-time.After(opts.Settle):
+		}
+		finished <- nil
+	}
+
+	if opts.InMemory {
+		pluginEnd, hostEnd := NewPipeTransport()
+		conn.transport = pluginEnd
+		go host(hostEnd)
+	} else {
+		upgrader := websocket.Upgrader{}
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ws, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				finished <- err
+				return
+			}
+			host(NewWebsocketTransport(ws))
+		})
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return report, err
+		}
+		srv := &http.Server{Handler: handler}
+		go srv.Serve(l)
+		defer srv.Close()
+
+		err = conn.dial("ws://" + l.Addr().String())
+		if err != nil {
+			return report, err
+		}
+	}
+
+	conn.start("registerPlugin", "replay")
+	err := <-finished
+	conn.transport.Close()
+	conn.WaitForPluginExit()
+	if err != nil {
+		return report, err
+	}
+
+	outMu.Lock()
+	defer outMu.Unlock()
+	report.Divergences = compareOutbound(frames, report.Outbound)
+	return report, nil
+}
+
+// compareOutbound matches the recorded outbound frames against the actual
+// messages, in order.
+func compareOutbound(frames []RecordedFrame, actual []json.RawMessage) []Divergence {
+	expected := []json.RawMessage{}
+	for _, f := range frames {
+		if f.Direction == DirectionOut {
+			expected = append(expected, f.Data)
+		}
+	}
+
+	divergences := []Divergence{}
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		d := Divergence{Index: i}
+		if i < len(expected) {
+			d.Expected = expected[i]
+		}
+		if i < len(actual) {
+			d.Actual = actual[i]
+		}
+		if d.Expected != nil && d.Actual != nil && sameJSON(d.Expected, d.Actual) {
+			continue
+		}
+		divergences = append(divergences, d)
+	}
+	return divergences
+}
+
+// sameJSON compares two JSON documents structurally.
+func sameJSON(expected, actual []byte) bool {
+	var e, a any
+	if json.Unmarshal(expected, &e) != nil || json.Unmarshal(actual, &a) != nil {
+		return string(expected) == string(actual)
+	}
+	return matchJSON(e, a)
+}
+
+// matchJSON is reflect.DeepEqual, except that a redacted expected value
+// matches anything.
+func matchJSON(expected, actual any) bool {
+	if expected == redactedValue {
+		return true
+	}
+	switch e := expected.(type) {
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		for k := range e {
+			if _, ok := a[k]; !ok || !matchJSON(e[k], a[k]) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		a, ok := actual.([]any)
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		for i := range e {
+			if !matchJSON(e[i], a[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(expected, actual)
+}
@@ -0,0 +1,47 @@
+package streamdeck
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+func TestReplay(t *testing.T) {
+	setTitle, _ := json.Marshal(events.NewESSetTitle("ABC123", "pressed", events.EventTargetBoth, 0))
+	frames := []RecordedFrame{
+		{Time: time.Now(), Direction: DirectionIn, Event: "keyDown", Data: json.RawMessage(`{"event":"keyDown","context":"ABC123","payload":{"settings":{}}}`)},
+		{Time: time.Now(), Direction: DirectionOut, Event: "setTitle", Data: setTitle},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		title    string
+		inMemory bool
+		ok       bool
+	}{
+		{"matching", "pressed", false, true},
+		{"diverging", "released", false, false},
+		{"matching in memory", "pressed", true, true},
+		{"diverging in memory", "released", true, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewWithLogger(testLogger{t: t})
+			c.RegisterHandler(func(e events.ERKeyDown) {
+				c.Send(events.NewESSetTitle(e.Context, tc.title, events.EventTargetBoth, 0))
+			})
+
+			report, err := Replay(&c, frames, ReplayOptions{Settle: 50 * time.Millisecond, InMemory: tc.inMemory})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Inbound != 1 {
+				t.Errorf("expected 1 inbound frame, got %d", report.Inbound)
+			}
+			if report.OK() != tc.ok {
+				t.Errorf("expected ok to be %t, divergences: %v", tc.ok, report.Divergences)
+			}
+		})
+	}
+}
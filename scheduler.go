@@ -0,0 +1,219 @@
+package streamdeck
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+// sleepGap is how far behind schedule a refresh has to be before we assume
+// the system was asleep rather than just busy.
+const sleepGap = 5 * time.Second
+
+// defaultRefresh is the refresh interval used when none is given.
+const defaultRefresh = time.Second
+
+// maxStagger limits how long the first refresh of a key can be put off to
+// spread refreshes out.
+const maxStagger = time.Second
+
+// RefreshFunc refreshes what a key shows. It is passed the willAppear event
+// of the key, and a context which is cancelled when the key disappears or
+// the registration is cancelled.
+type RefreshFunc func(ctx context.Context, e events.ERWillAppear)
+
+// Scheduler runs refresh functions periodically for each visible instance of
+// an action, replacing loops over a map of contexts with time.Sleep.
+//
+// Keys are refreshed only while they are shown. The first refresh of each
+// key is staggered by up to a second, and keys keep that offset, so that
+// many keys appearing at once do not all refresh together. Refreshing pauses
+// while the system is asleep, and every key is refreshed immediately when it
+// wakes. Keys in multi-actions are not refreshed.
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    map[*refreshJob]bool
+	visible map[string]events.ERWillAppear
+}
+
+type refreshJob struct {
+	ctx      context.Context
+	action   string
+	interval time.Duration
+	fn       RefreshFunc
+	runs     map[string]*refreshRun // by context
+}
+
+type refreshRun struct {
+	cancel context.CancelFunc
+	wake   chan struct{}
+}
+
+// NewScheduler creates a Scheduler. It should be created before Connect, so
+// that it sees every willAppear.
+func NewScheduler(conn *Connection) *Scheduler {
+	s := &Scheduler{
+		jobs:    make(map[*refreshJob]bool),
+		visible: make(map[string]events.ERWillAppear),
+	}
+	conn.observe(func(event any) {
+		switch e := event.(type) {
+		case events.ERWillAppear:
+			if !e.Payload.IsInMultiAction {
+				s.appear(e)
+			}
+		case events.ERWillDisappear:
+			s.disappear(e.Context)
+		case events.ERApplicationSystemDidWakeUp:
+			s.wake()
+		}
+	})
+	return s
+}
+
+// Register calls fn every interval for each visible instance of the action
+// with the given UUID, until ctx is cancelled. An interval of zero or less
+// refreshes once a second.
+func (s *Scheduler) Register(ctx context.Context, action string, interval time.Duration, fn RefreshFunc) {
+	if interval <= 0 {
+		interval = defaultRefresh
+	}
+	job := &refreshJob{
+		ctx:      ctx,
+		action:   action,
+		interval: interval,
+		fn:       fn,
+		runs:     make(map[string]*refreshRun),
+	}
+	s.mu.Lock()
+	s.jobs[job] = true
+	for _, e := range s.visible {
+		if e.Action == action {
+			s.start(job, e)
+		}
+	}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.jobs, job)
+		for _, run := range job.runs {
+			run.cancel()
+		}
+		job.runs = nil
+		s.mu.Unlock()
+	}()
+}
+
+// Visible returns the willAppear events of the instances of an action which
+// are currently shown.
+func (s *Scheduler) Visible(action string) []events.ERWillAppear {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	visible := []events.ERWillAppear{}
+	for _, e := range s.visible {
+		if e.Action == action {
+			visible = append(visible, e)
+		}
+	}
+	return visible
+}
+
+func (s *Scheduler) appear(e events.ERWillAppear) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stop(e.Context)
+	s.visible[e.Context] = e
+	for job := range s.jobs {
+		if job.action == e.Action && job.ctx.Err() == nil {
+			s.start(job, e)
+		}
+	}
+}
+
+func (s *Scheduler) disappear(context string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.visible, context)
+	s.stop(context)
+}
+
+// wake refreshes every visible key at once.
+func (s *Scheduler) wake() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for job := range s.jobs {
+		for _, run := range job.runs {
+			select {
+			case run.wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// start begins refreshing a key. The caller must hold the lock.
+func (s *Scheduler) start(job *refreshJob, e events.ERWillAppear) {
+	ctx, cancel := context.WithCancel(job.ctx)
+	run := &refreshRun{cancel: cancel, wake: make(chan struct{}, 1)}
+	job.runs[e.Context] = run
+	go job.loop(ctx, e, run.wake)
+}
+
+// stop stops refreshing a key for every job. The caller must hold the lock.
+func (s *Scheduler) stop(context string) {
+	for job := range s.jobs {
+		if run, ok := job.runs[context]; ok {
+			run.cancel()
+			delete(job.runs, context)
+		}
+	}
+}
+
+func (job *refreshJob) loop(ctx context.Context, e events.ERWillAppear, wake chan struct{}) {
+	delay := stagger(e.Context, job.interval)
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	due := time.Now().Round(0).Add(delay) // wall clock, which keeps running while asleep
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+			if !t.Stop() {
+				select {
+				case <-t.C:
+				default:
+				}
+			}
+		case <-t.C:
+			if time.Now().Round(0).Sub(due) > sleepGap {
+				// the system was asleep, wait for the wake up to refresh
+				due = time.Now().Round(0).Add(job.interval)
+				t.Reset(job.interval)
+				continue
+			}
+		}
+
+		job.fn(ctx, e)
+		due = time.Now().Round(0).Add(job.interval)
+		t.Reset(job.interval)
+	}
+}
+
+// stagger returns how long to wait before first refreshing a key, spread
+// evenly by context but the same each time the key appears.
+func stagger(context string, interval time.Duration) time.Duration {
+	spread := min(interval, maxStagger)
+	if spread <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(context))
+	return time.Duration(h.Sum64() % uint64(spread))
+}
@@ -0,0 +1,111 @@
+package streamdeck
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+func TestScheduler(t *testing.T) {
+	c := New()
+	s := NewScheduler(&c)
+
+	mu := sync.Mutex{}
+	counts := map[string]int{}
+	count := func(context string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return counts[context]
+	}
+
+	appear := func(context string, multi bool) {
+		e := events.ERWillAppear{ERCommon: events.ERCommon{Action: "com.example.clock", Context: context}}
+		e.Payload.IsInMultiAction = multi
+		c.handle(e)
+	}
+	appear("A", false)
+	appear("M", true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Register(ctx, "com.example.clock", 40*time.Millisecond, func(ctx context.Context, e events.ERWillAppear) {
+		mu.Lock()
+		counts[e.Context]++
+		mu.Unlock()
+	})
+	appear("B", false)
+
+	time.Sleep(time.Second + 100*time.Millisecond)
+	if count("A") < 3 || count("B") < 3 {
+		t.Errorf("expected regular refreshes, got %v", counts)
+	}
+	if count("M") != 0 {
+		t.Error("expected multi-actions not to be refreshed")
+	}
+	if v := s.Visible("com.example.clock"); len(v) != 2 {
+		t.Errorf("expected 2 visible keys, got %d", len(v))
+	}
+
+	c.handle(events.ERWillDisappear{ERCommon: events.ERCommon{Action: "com.example.clock", Context: "B"}})
+	time.Sleep(20 * time.Millisecond)
+	b := count("B")
+	time.Sleep(100 * time.Millisecond)
+	if count("B") != b {
+		t.Error("expected a hidden key not to be refreshed")
+	}
+
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	a := count("A")
+	time.Sleep(100 * time.Millisecond)
+	if count("A") != a {
+		t.Error("expected refreshing to stop when cancelled")
+	}
+}
+
+func TestSchedulerWake(t *testing.T) {
+	c := New()
+	s := NewScheduler(&c)
+	refreshed := make(chan string, 10)
+	s.Register(context.Background(), "com.example.clock", time.Hour, func(ctx context.Context, e events.ERWillAppear) {
+		refreshed <- e.Context
+	})
+	c.handle(events.ERWillAppear{ERCommon: events.ERCommon{Action: "com.example.clock", Context: "A"}})
+	// the first refresh is staggered by up to a second
+	select {
+	case <-refreshed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no first refresh")
+	}
+
+	c.handle(events.ERApplicationSystemDidWakeUp{})
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("no refresh on waking")
+	}
+}
+
+func TestSchedulerDefaultInterval(t *testing.T) {
+	c := New()
+	s := NewScheduler(&c)
+	c.handle(events.ERWillAppear{ERCommon: events.ERCommon{Action: "com.example.clock", Context: "A"}})
+
+	mu := sync.Mutex{}
+	count := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Register(ctx, "com.example.clock", 0, func(ctx context.Context, e events.ERWillAppear) {
+		mu.Lock()
+		count++
+		mu.Unlock()
+	})
+	time.Sleep(1100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if count > 2 {
+		t.Errorf("expected a zero interval to use the default, got %d refreshes", count)
+	}
+}
@@ -0,0 +1,187 @@
+package streamdeck
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+// StatefulOptions describes an action with more than one state.
+type StatefulOptions struct {
+	// States is the number of states in the manifest, defaults to 2.
+	States int
+	// Apply is called when the user presses a key, with the state the key
+	// should move to: the next state, or the state chosen by the user for a
+	// multi-action. It returns the state the key should show, usually the
+	// desired state, or an error to leave the state as it was. If nil,
+	// the desired state is always accepted.
+	Apply func(context string, desired int) (int, error)
+}
+
+// StatefulAction keeps the state of every instance of an action with
+// multiple states, such as a toggle, and keeps the Stream Deck application
+// showing it.
+//
+// The state held here is canonical. The application moves a key to its next
+// state by itself when pressed, and remembers the state of a key while it is
+// hidden, so it is corrected with setState after every press and whenever a
+// key appears showing the wrong state. When the state changes outside the
+// plugin, for example a device being muted elsewhere, use Set or SetAll.
+type StatefulAction struct {
+	conn   *Connection
+	action string
+	opts   StatefulOptions
+
+	mu      sync.Mutex
+	states  map[string]int  // context to canonical state
+	visible map[string]bool // contexts of keys currently shown, not in multi-actions
+}
+
+// NewStatefulAction creates a StatefulAction for instances of the action with
+// the given UUID. It should be created before Connect, so that it sees every
+// willAppear.
+func NewStatefulAction(conn *Connection, action string, opts StatefulOptions) *StatefulAction {
+	if opts.States < 2 {
+		opts.States = 2
+	}
+	s := &StatefulAction{
+		conn:    conn,
+		action:  action,
+		opts:    opts,
+		states:  make(map[string]int),
+		visible: make(map[string]bool),
+	}
+	conn.observe(func(event any) {
+		switch e := event.(type) {
+		case events.ERWillAppear:
+			if e.Action == action {
+				s.appear(e.Context, e.Payload.State, e.Payload.IsInMultiAction)
+			}
+		case events.ERWillDisappear:
+			if e.Action == action {
+				s.mu.Lock()
+				delete(s.visible, e.Context)
+				s.mu.Unlock()
+			}
+		case events.ERKeyUp:
+			if e.Action == action {
+				s.press(e.Context, e.Payload.State, e.Payload.UserDesiredState, e.Payload.IsInMultiAction)
+			}
+		}
+	})
+	return s
+}
+
+// State returns the canonical state of a context.
+func (s *StatefulAction) State(context string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[context]
+	return state, ok
+}
+
+// Set changes the state of a context, updating the key if it is shown.
+func (s *StatefulAction) Set(context string, state int) error {
+	s.mu.Lock()
+	s.states[context] = state
+	visible := s.visible[context]
+	s.mu.Unlock()
+	if !visible {
+		return nil
+	}
+	return s.conn.Send(events.NewESSetState(context, state))
+}
+
+// SetAll changes the state of every instance of the action, for state that
+// is shared between them.
+func (s *StatefulAction) SetAll(state int) error {
+	s.mu.Lock()
+	contexts := []string{}
+	for context := range s.states {
+		s.states[context] = state
+	}
+	for context := range s.visible {
+		contexts = append(contexts, context)
+	}
+	s.mu.Unlock()
+
+	var firstErr error
+	for _, context := range contexts {
+		err := s.conn.Send(events.NewESSetState(context, state))
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// appear adopts the state the application reports for a new context, or
+// corrects it if the canonical state is already known.
+func (s *StatefulAction) appear(context string, reported *int, multi bool) {
+	s.mu.Lock()
+	state, known := s.states[context]
+	if !multi {
+		s.visible[context] = true
+	}
+	if !known {
+		if reported != nil {
+			s.states[context] = *reported
+		}
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	if multi || (reported != nil && *reported == state) {
+		return
+	}
+	err := s.conn.Send(events.NewESSetState(context, state))
+	if err != nil {
+		s.conn.logger.Error("cannot restore state: " + err.Error())
+	}
+}
+
+// press applies the state a key press asks for, and corrects the state the
+// application has moved the key to if it differs.
+func (s *StatefulAction) press(context string, reported *int, desired *int, multi bool) {
+	s.mu.Lock()
+	current, known := s.states[context]
+	s.mu.Unlock()
+	if !known && reported != nil {
+		current = *reported
+	}
+
+	next := (current + 1) % s.opts.States
+	if desired != nil {
+		next = *desired
+	}
+
+	state := next
+	if s.opts.Apply != nil {
+		applied, err := s.opts.Apply(context, next)
+		if err != nil {
+			s.conn.logger.Error(fmt.Sprintf("cannot change state of %s to %d: %s", context, next, err))
+			applied = current
+		}
+		state = applied
+	}
+
+	s.mu.Lock()
+	s.states[context] = state
+	s.mu.Unlock()
+
+	// keys in multi-actions do not show a state of their own, and keys
+	// without a reported state have only one
+	if multi || reported == nil {
+		return
+	}
+	// the application moves the key on from the state it reported by itself
+	if (*reported+1)%s.opts.States == state {
+		return
+	}
+	err := s.conn.Send(events.NewESSetState(context, state))
+	if err != nil {
+		s.conn.logger.Error("cannot set state: " + err.Error())
+	}
+}
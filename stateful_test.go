@@ -0,0 +1,72 @@
+package streamdeck
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+func statefulKeyUp(context string, state int, desired *int, multi bool) events.ERKeyUp {
+	e := events.ERKeyUp{ERCommon: events.ERCommon{Action: "com.example.mute", Context: context}}
+	e.Payload.State = &state
+	e.Payload.UserDesiredState = desired
+	e.Payload.IsInMultiAction = multi
+	return e
+}
+
+func statefulAppear(context string, state int) events.ERWillAppear {
+	e := events.ERWillAppear{ERCommon: events.ERCommon{Action: "com.example.mute", Context: context}}
+	e.Payload.State = &state
+	return e
+}
+
+func TestStatefulAction(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	fail := false
+	applied := []int{}
+	s := NewStatefulAction(&c, "com.example.mute", StatefulOptions{
+		Apply: func(context string, desired int) (int, error) {
+			if fail {
+				return 0, errors.New("backend down")
+			}
+			applied = append(applied, desired)
+			return desired, nil
+		},
+	})
+
+	c.handle(statefulAppear("A", 0))
+	// a normal toggle needs no correction
+	c.handle(statefulKeyUp("A", 0, nil, false))
+	if state, _ := s.State("A"); state != 1 {
+		t.Errorf("expected state 1, got %d", state)
+	}
+	// a failed toggle is undone
+	fail = true
+	c.handle(statefulKeyUp("A", 1, nil, false))
+	fail = false
+	// a multi-action asks for a state
+	one := 1
+	c.handle(statefulKeyUp("M", 0, &one, true))
+	// the backend changes, and A reappears showing a stale state
+	s.Set("A", 0)
+	c.handle(events.ERWillDisappear{ERCommon: events.ERCommon{Action: "com.example.mute", Context: "A"}})
+	s.Set("A", 1)
+	c.handle(statefulAppear("A", 0))
+	s.SetAll(0)
+	pluginEnd.Close()
+
+	if len(applied) != 2 || applied[0] != 1 || applied[1] != 1 {
+		t.Errorf("wrong states applied %v", applied)
+	}
+
+	sent := sentFrames(t, hostEnd)
+	expected := []string{
+		`{"event":"setState","context":"A","payload":{"state":1}}`,
+		`{"event":"setState","context":"A","payload":{"state":0}}`,
+		`{"event":"setState","context":"A","payload":{"state":1}}`,
+		`{"event":"setState","context":"A","payload":{"state":0}}`,
+	}
+	checkFrames(t, sent, expected)
+}
@@ -3,12 +3,12 @@
 package streamdeck
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"reflect"
+	"sync"
 
 	"github.com/tardisx/streamdeck-plugin/events"
 
@@ -33,19 +33,35 @@ func (nl nullLogger) Error(string, ...any) {}
 func (nl nullLogger) Debug(string, ...any) {}
 
 type Connection struct {
-	ws       *websocket.Conn
-	logger   logger
-	handlers map[reflect.Type]reflect.Value
-	done     chan (bool)
+	transport Transport
+	logger    logger
+	handlers  map[reflect.Type]reflect.Value
+	done      chan (bool)
+	recorder  *Recorder
+	coalescer *coalescer
+	dedupe    *sendCache
+	observers *observers
+}
+
+// observers are internal functions which see every incoming event before
+// the registered handler does. Helpers that need to track state, such as
+// which contexts are visible, register themselves here so that they do not
+// use up the single handler allowed per event type.
+type observers struct {
+	mu           sync.Mutex
+	interceptors []func(any) bool
+	fns          []func(any)
+	sent         []func(any)
 }
 
 // New creates a new struct for communication with the streamdeck
 // plugin API. The websocket will not connect until Connect is called.
 func New() Connection {
 	return Connection{
-		handlers: make(map[reflect.Type]reflect.Value),
-		logger:   nullLogger{},
-		done:     make(chan bool),
+		handlers:  make(map[reflect.Type]reflect.Value),
+		logger:    nullLogger{},
+		done:      make(chan bool),
+		observers: &observers{},
 	}
 }
 
@@ -57,6 +73,34 @@ func NewWithLogger(l logger) Connection {
 	return c
 }
 
+// SetRecorder attaches a Recorder, which will be sent every frame received
+// from and sent to the Stream Deck API. Pass nil to stop recording. The
+// recorder is not closed by the connection.
+func (conn *Connection) SetRecorder(r *Recorder) {
+	conn.recorder = r
+}
+
+// NewWithTransport is the same as New, but the connection will use the
+// given Transport instead of dialling the Stream Deck websocket. See
+// NewPipeTransport and NewStdioTransport.
+func NewWithTransport(t Transport) Connection {
+	c := New()
+	c.transport = t
+	return c
+}
+
+// ApplicationInfo returns the application info passed to the plugin by the
+// Stream Deck application, which includes the devices connected and the
+// user's colour preferences. It is only available after Connect.
+func ApplicationInfo() (events.Info, error) {
+	info := events.Info{}
+	if flagInfo == "" {
+		return info, errors.New("no application info, call Connect first")
+	}
+	err := json.Unmarshal([]byte(flagInfo), &info)
+	return info, err
+}
+
 // parseFlags parses the command line flags to get the values provided
 // by the Stream Deck plugin API.
 func parseFlags() {
@@ -67,29 +111,51 @@ func parseFlags() {
 	flag.Parse()
 }
 
-// Connect connects the plugin to the Stream Deck API via the websocket.
+// Connect connects the plugin to the Stream Deck API via the websocket, or
+// the Transport given to NewWithTransport.
 // Once connected, events will be passed to handlers you have registered.
 // Handlers should thus be registered via RegisterHandler before calling
 // Connect.
 // Connect returns immediately if the connection is successful, you should
 // then call WaitForPluginExit to block until the connection is closed.
 func (conn *Connection) Connect() error {
-
 	parseFlags()
-	c, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:%d", flagPort), nil)
+	if conn.transport == nil {
+		err := conn.dial(fmt.Sprintf("ws://localhost:%d", flagPort))
+		if err != nil {
+			return err
+		}
+	}
+	conn.start(flagEvent, UUID)
+	return nil
+}
+
+// dial connects the websocket at url and uses it as the transport.
+func (conn *Connection) dial(url string) error {
+	c, _, err := websocket.DefaultDialer.Dial(url, nil)
 	if err != nil {
 		return err
 	}
+	conn.transport = NewWebsocketTransport(c)
+	return nil
+}
+
+// start registers the plugin over the transport and starts the reader.
+func (conn *Connection) start(registerEvent string, uuid string) {
+	if conn.dedupe != nil {
+		// a new connection means a host which has seen nothing yet
+		conn.dedupe.reset()
+	}
 
-	conn.ws = c
 	msg := events.ESOpenMessage{
 		ESCommonNoContext: events.ESCommonNoContext{
-			Event: flagEvent,
+			Event: registerEvent,
 		},
-		UUID: UUID,
+		UUID: uuid,
 	}
 	conn.logger.Debug(fmt.Sprintf("writing openMessage: %+v", msg))
-	err = c.WriteJSON(msg)
+	b, _ := json.Marshal(msg)
+	err := conn.transport.WriteFrame(b)
 	if err != nil {
 		conn.logger.Error(err.Error())
 		panic(err)
@@ -98,8 +164,6 @@ func (conn *Connection) Connect() error {
 	// run the reader forever
 	conn.logger.Info("starting reader")
 	go conn.reader()
-
-	return nil
 }
 
 // WaitForPluginExit waits until the Stream Deck API closes
@@ -142,14 +206,120 @@ func (conn *Connection) RegisterHandler(handler any) {
 
 // Send sends a message to the API. It should be one of the
 // events.ES* structs, such as events.ESOpenURL.
+// If coalescing is enabled (see SetCoalescing), title, image and feedback
+// updates may be delayed or replaced by a later update. If deduplication is
+// enabled (see SetDeduplication), updates which would change nothing are
+// dropped.
 func (conn *Connection) Send(e any) error {
-	b, _ := json.Marshal(e)
+	if conn.dedupe != nil && conn.dedupe.unchanged(e) {
+		conn.logger.Debug(fmt.Sprintf("not sending unchanged %T", e))
+		return nil
+	}
+	err := conn.send(e)
+	if err != nil {
+		if conn.dedupe != nil {
+			// we no longer know what the host has
+			conn.dedupe.forget(contextOf(e))
+		}
+		return err
+	}
+
+	conn.observers.mu.Lock()
+	fns := conn.observers.sent
+	conn.observers.mu.Unlock()
+	for _, fn := range fns {
+		fn(e)
+	}
+	return nil
+}
+
+// send sends a message after deduplication, coalescing it if enabled.
+func (conn *Connection) send(e any) error {
+	if conn.coalescer != nil {
+		handled, err := conn.coalescer.offer(e)
+		if handled {
+			return err
+		}
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return conn.write(b)
+}
+
+// write sends an encoded message over the transport.
+func (conn *Connection) write(b []byte) error {
 	conn.logger.Debug(fmt.Sprintf("sending: %s", string(b)))
+	if conn.transport == nil {
+		return errors.New("not connected")
+	}
+	conn.record(DirectionOut, b)
+
+	return conn.transport.WriteFrame(b)
+}
+
+// record passes a frame to the recorder, if there is one.
+func (conn *Connection) record(direction string, b []byte) {
+	if conn.recorder == nil {
+		return
+	}
+	err := conn.recorder.Record(direction, b)
+	if err != nil {
+		conn.logger.Error("cannot record frame: " + err.Error())
+	}
+}
+
+// observe adds an internal function to be called for every incoming event.
+func (conn *Connection) observe(fn func(any)) {
+	conn.observers.mu.Lock()
+	conn.observers.fns = append(conn.observers.fns, fn)
+	conn.observers.mu.Unlock()
+}
+
+// observeSent adds an internal function to be called for every message
+// successfully sent with Send.
+func (conn *Connection) observeSent(fn func(any)) {
+	conn.observers.mu.Lock()
+	conn.observers.sent = append(conn.observers.sent, fn)
+	conn.observers.mu.Unlock()
+}
 
-	return conn.ws.WriteJSON(e)
+// intercept adds an internal function which sees every incoming event before
+// the observers, and can swallow it by returning true. A swallowed event is
+// not seen by observers or handlers unless the interceptor later passes it
+// on with deliver.
+func (conn *Connection) intercept(fn func(any) bool) {
+	conn.observers.mu.Lock()
+	conn.observers.interceptors = append(conn.observers.interceptors, fn)
+	conn.observers.mu.Unlock()
 }
 
 func (conn *Connection) handle(event any) {
+	if conn.dedupe != nil {
+		conn.dedupe.seen(event)
+	}
+	conn.observers.mu.Lock()
+	interceptors := conn.observers.interceptors
+	conn.observers.mu.Unlock()
+	for _, fn := range interceptors {
+		if fn(event) {
+			conn.logger.Debug(fmt.Sprintf("handle: %T intercepted", event))
+			return
+		}
+	}
+	conn.deliver(event)
+}
+
+// deliver passes an event to the observers and then the registered handler.
+func (conn *Connection) deliver(event any) {
+	conn.observers.mu.Lock()
+	fns := conn.observers.fns
+	conn.observers.mu.Unlock()
+	for _, fn := range fns {
+		fn(event)
+	}
+
 	// conn.logger.Debug(fmt.Sprintf("handle: incoming a %T", event))
 	argType := reflect.TypeOf(event)
 	handler, ok := conn.handlers[argType]
@@ -171,16 +341,15 @@ func (conn *Connection) handle(event any) {
 
 func (conn *Connection) reader() {
 	for {
-		_, r, err := conn.ws.NextReader()
+		b, err := conn.transport.ReadFrame()
 		if err != nil {
 			conn.logger.Error(err.Error())
 			break
 		}
+		conn.record(DirectionIn, b)
 
-		b := bytes.Buffer{}
-		r = io.TeeReader(r, &b)
 		base := events.ERBase{}
-		err = json.NewDecoder(r).Decode(&base)
+		err = json.Unmarshal(b, &base)
 		if err != nil {
 			conn.logger.Error("cannot decode: " + err.Error())
 			continue
@@ -192,14 +361,14 @@ func (conn *Connection) reader() {
 			continue
 		}
 
-		d, err := conn.unmarshalToConcrete(t, b.Bytes())
+		d, err := conn.unmarshalToConcrete(t, b)
 		if err != nil {
 			conn.logger.Error("cannot unmarshal: " + err.Error())
 			continue
 		}
 		conn.handle(d)
 	}
-	conn.logger.Info("websocket closed, shutting down reader")
+	conn.logger.Info("transport closed, shutting down reader")
 	conn.done <- true
 }
 
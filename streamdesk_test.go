@@ -70,3 +70,30 @@ func TestUmmarshal(t *testing.T) {
 	}
 
 }
+
+// sentFrames returns every frame sent to the host end of a pipe transport.
+// The plugin end must be closed first.
+func sentFrames(t *testing.T, hostEnd Transport) []string {
+	t.Helper()
+	sent := []string{}
+	for {
+		b, err := hostEnd.ReadFrame()
+		if err != nil {
+			return sent
+		}
+		sent = append(sent, string(b))
+	}
+}
+
+// checkFrames compares the frames sent with those expected.
+func checkFrames(t *testing.T, sent []string, expected []string) {
+	t.Helper()
+	if len(sent) != len(expected) {
+		t.Fatalf("expected %d messages, got %d: %v", len(expected), len(sent), sent)
+	}
+	for i := range expected {
+		if sent[i] != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], sent[i])
+		}
+	}
+}
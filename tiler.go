@@ -0,0 +1,186 @@
+package streamdeck
+
+import (
+	"image"
+	"image/png"
+	"sync"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+	"github.com/tardisx/streamdeck-plugin/tools"
+)
+
+// DefaultTileGap is the gap between keys assumed by a Tiler, in pixels at
+// the key size of tools.KeySize2x. It roughly matches the spacing of keys on
+// the hardware, so that straight lines stay straight across the gaps.
+const DefaultTileGap = 36
+
+// Tiler spreads one image across all the visible instances of an action on
+// a device, each key showing its own part of the image. Instances are
+// tracked as they appear and disappear, and the image is re-tiled whenever
+// the set of keys changes.
+type Tiler struct {
+	conn   *Connection
+	action string
+
+	// Gap is the space between keys, in pixels of the source image after
+	// it has been scaled to cover the block of keys. Defaults to
+	// DefaultTileGap, set it before calling Show.
+	Gap int
+	// Quality is the scaling used to fit the image to the keys.
+	Quality tools.Quality
+
+	mu      sync.Mutex
+	devices map[string]*tiledDevice
+}
+
+type tiledDevice struct {
+	keys  map[string]image.Point // context to column, row
+	image image.Image
+}
+
+// NewTiler creates a Tiler for instances of the action with the given UUID.
+// It should be created before Connect, so that it sees every willAppear.
+func NewTiler(conn *Connection, action string) *Tiler {
+	t := &Tiler{
+		conn:    conn,
+		action:  action,
+		Gap:     DefaultTileGap,
+		Quality: tools.QualityBiLinear,
+		devices: make(map[string]*tiledDevice),
+	}
+	conn.observe(func(event any) {
+		switch e := event.(type) {
+		case events.ERWillAppear:
+			if e.Action == action && !e.Payload.IsInMultiAction {
+				t.add(e.Device, e.Context, image.Pt(e.Payload.Coordinates.Column, e.Payload.Coordinates.Row))
+			}
+		case events.ERWillDisappear:
+			if e.Action == action {
+				t.remove(e.Device, e.Context)
+			}
+		}
+	})
+	return t
+}
+
+// Show tiles img across the instances of the action on a device. The image
+// is scaled to cover the bounding box of the keys, keeping its aspect ratio,
+// and kept so that it can be re-tiled as keys come and go.
+func (t *Tiler) Show(device string, img image.Image) error {
+	t.mu.Lock()
+	d := t.device(device)
+	d.image = img
+	t.mu.Unlock()
+	return t.tile(device)
+}
+
+// Keys returns the contexts currently tiled on a device, with their column
+// and row.
+func (t *Tiler) Keys(device string) map[string]image.Point {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys := map[string]image.Point{}
+	if d, ok := t.devices[device]; ok {
+		for context, pos := range d.keys {
+			keys[context] = pos
+		}
+	}
+	return keys
+}
+
+// device returns the state for a device, creating it if needed. The caller
+// must hold the lock.
+func (t *Tiler) device(device string) *tiledDevice {
+	d, ok := t.devices[device]
+	if !ok {
+		d = &tiledDevice{keys: make(map[string]image.Point)}
+		t.devices[device] = d
+	}
+	return d
+}
+
+func (t *Tiler) add(device, context string, pos image.Point) {
+	t.mu.Lock()
+	t.device(device).keys[context] = pos
+	t.mu.Unlock()
+	t.retile(device)
+}
+
+func (t *Tiler) remove(device, context string) {
+	t.mu.Lock()
+	if d, ok := t.devices[device]; ok {
+		delete(d.keys, context)
+	}
+	t.mu.Unlock()
+	t.retile(device)
+}
+
+// retile tiles again after the keys have changed, logging any error as
+// there is no caller to return it to.
+func (t *Tiler) retile(device string) {
+	err := t.tile(device)
+	if err != nil {
+		t.conn.logger.Error("cannot tile image: " + err.Error())
+	}
+}
+
+// tile slices the device's image and sends each key its tile.
+func (t *Tiler) tile(device string) error {
+	t.mu.Lock()
+	d, ok := t.devices[device]
+	if !ok || d.image == nil || len(d.keys) == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	img := d.image
+	keys := make(map[string]image.Point, len(d.keys))
+	for context, pos := range d.keys {
+		keys[context] = pos
+	}
+	t.mu.Unlock()
+
+	tiles := Tile(img, keys, tools.KeySize2x.X, t.Gap, t.Quality)
+	for context, tile := range tiles {
+		p, err := tools.EncodePNG(tile, png.BestSpeed)
+		if err != nil {
+			return err
+		}
+		err = t.conn.Send(events.NewESSetImage(context, p, events.EventTargetBoth, nil))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tile slices img into square tiles of keySize pixels for keys at the given
+// positions (column, row), keyed by context. The image is scaled to cover the
+// bounding box of the keys including a gap between each, and the parts that
+// fall in the gaps are not shown.
+func Tile(img image.Image, keys map[string]image.Point, keySize int, gap int, q tools.Quality) map[string]*image.RGBA {
+	tiles := make(map[string]*image.RGBA, len(keys))
+	if len(keys) == 0 {
+		return tiles
+	}
+
+	var lo, hi image.Point
+	first := true
+	for _, pos := range keys {
+		if first {
+			lo, hi = pos, pos
+			first = false
+			continue
+		}
+		lo = image.Pt(min(lo.X, pos.X), min(lo.Y, pos.Y))
+		hi = image.Pt(max(hi.X, pos.X), max(hi.Y, pos.Y))
+	}
+	cols, rows := hi.X-lo.X+1, hi.Y-lo.Y+1
+	size := image.Pt(cols*keySize+(cols-1)*gap, rows*keySize+(rows-1)*gap)
+	scaled := tools.Fill(img, size, q)
+
+	for context, pos := range keys {
+		origin := image.Pt((pos.X-lo.X)*(keySize+gap), (pos.Y-lo.Y)*(keySize+gap))
+		tiles[context] = tools.Crop(scaled, image.Rectangle{Min: origin, Max: origin.Add(image.Pt(keySize, keySize))})
+	}
+	return tiles
+}
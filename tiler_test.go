@@ -0,0 +1,127 @@
+package streamdeck
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+	"github.com/tardisx/streamdeck-plugin/tools"
+)
+
+func tileAppear(action, context string, col int, multi bool) events.ERWillAppear {
+	e := events.ERWillAppear{ERCommon: events.ERCommon{Action: action, Context: context, Device: "DEV1"}}
+	e.Payload.Coordinates.Column = col
+	e.Payload.IsInMultiAction = multi
+	return e
+}
+
+// tileColours decodes the setImage frames sent to each context, returning the
+// colours at the left and right of each tile.
+func tileColours(t *testing.T, frames []string) map[string][2]color.RGBA {
+	t.Helper()
+	colours := map[string][2]color.RGBA{}
+	for _, f := range frames {
+		e := events.ESSetImage{}
+		if err := json.Unmarshal([]byte(f), &e); err != nil || e.Event != "setImage" {
+			t.Fatalf("unexpected frame %s", f)
+		}
+		if _, ok := colours[e.Context]; ok {
+			t.Errorf("%s: sent more than one tile", e.Context)
+		}
+		img, err := tools.DecodePayload(e.Payload.Image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := img.Bounds()
+		left := color.RGBAModel.Convert(img.At(b.Min.X+b.Dx()/8, b.Min.Y+b.Dy()/2)).(color.RGBA)
+		right := color.RGBAModel.Convert(img.At(b.Max.X-b.Dx()/8, b.Min.Y+b.Dy()/2)).(color.RGBA)
+		colours[e.Context] = [2]color.RGBA{left, right}
+	}
+	return colours
+}
+
+func TestTile(t *testing.T) {
+	// left half red, right half blue
+	src := image.NewRGBA(image.Rect(0, 0, 30, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 30; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 15 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+
+	// a 2x1 block with a key missing from the middle of a 3 column span
+	tiles := Tile(src, map[string]image.Point{
+		"left":  {X: 1, Y: 2},
+		"right": {X: 3, Y: 2},
+	}, 10, 5, tools.QualityNearest)
+
+	if len(tiles) != 2 {
+		t.Fatalf("expected 2 tiles, got %d", len(tiles))
+	}
+	for context, want := range map[string]color.RGBA{
+		"left":  {R: 255, A: 255},
+		"right": {B: 255, A: 255},
+	} {
+		tile := tiles[context]
+		if tile.Bounds().Dx() != 10 || tile.Bounds().Dy() != 10 {
+			t.Errorf("%s: wrong size %v", context, tile.Bounds())
+		}
+		if got := tile.RGBAAt(5, 5); got != want {
+			t.Errorf("%s: expected %v, got %v", context, want, got)
+		}
+	}
+}
+
+func TestTiler(t *testing.T) {
+	pluginEnd, hostEnd := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	tiler := NewTiler(&c, "com.example.tile")
+
+	// left half red, right half blue
+	src := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			col := color.RGBA{R: 255, A: 255}
+			if x >= 10 {
+				col = color.RGBA{B: 255, A: 255}
+			}
+			src.Set(x, y, col)
+		}
+	}
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+
+	c.handle(tileAppear("com.example.tile", "left", 0, false))
+	c.handle(tileAppear("com.example.tile", "right", 1, false))
+	c.handle(tileAppear("com.example.other", "other", 2, false))
+	c.handle(tileAppear("com.example.tile", "multi", 0, true))
+	if keys := tiler.Keys("DEV1"); len(keys) != 2 || keys["right"] != image.Pt(1, 0) {
+		t.Fatalf("wrong keys %v", keys)
+	}
+	tiler.Gap = 0
+	if err := tiler.Show("DEV1", src); err != nil {
+		t.Fatal(err)
+	}
+
+	// with the right key gone, the left one is re-tiled with the whole image
+	c.handle(events.ERWillDisappear{ERCommon: events.ERCommon{Action: "com.example.tile", Context: "right", Device: "DEV1"}})
+	pluginEnd.Close()
+
+	sent := sentFrames(t, hostEnd)
+	if len(sent) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(sent))
+	}
+	shown := tileColours(t, sent[:2])
+	if shown["left"] != [2]color.RGBA{red, red} || shown["right"] != [2]color.RGBA{blue, blue} {
+		t.Errorf("wrong tiles %v", shown)
+	}
+	retiled := tileColours(t, sent[2:])
+	if retiled["left"] != [2]color.RGBA{red, blue} {
+		t.Errorf("wrong tile after a key went away %v", retiled)
+	}
+}
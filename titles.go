@@ -0,0 +1,80 @@
+package streamdeck
+
+import (
+	"sync"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+	"github.com/tardisx/streamdeck-plugin/tools/render"
+)
+
+// TitleTracker remembers the title and title parameters of every context, as
+// reported by events.ERTitleParametersDidChange, so that images can be drawn
+// with the text style the user picked.
+type TitleTracker struct {
+	mu     sync.Mutex
+	titles map[titleState]trackedTitle
+}
+
+type titleState struct {
+	context string
+	state   int
+}
+
+type trackedTitle struct {
+	title  string
+	params events.TitleParameters
+}
+
+// NewTitleTracker creates a TitleTracker. It should be created before Connect,
+// as the parameters are only sent when an action appears or the user changes
+// them.
+func NewTitleTracker(conn *Connection) *TitleTracker {
+	t := &TitleTracker{titles: make(map[titleState]trackedTitle)}
+	conn.observe(func(event any) {
+		switch e := event.(type) {
+		case events.ERTitleParametersDidChange:
+			t.mu.Lock()
+			t.titles[titleState{context: e.Context, state: e.Payload.State}] = trackedTitle{
+				title:  e.Payload.Title,
+				params: e.Payload.TitleParameters,
+			}
+			t.mu.Unlock()
+		case events.ERWillDisappear:
+			t.mu.Lock()
+			for k := range t.titles {
+				if k.context == e.Context {
+					delete(t.titles, k)
+				}
+			}
+			t.mu.Unlock()
+		}
+	})
+	return t
+}
+
+// Get returns the title the user set and its parameters for a context in a
+// given state.
+func (t *TitleTracker) Get(context string, state int) (string, events.TitleParameters, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tt, ok := t.titles[titleState{context: context, state: state}]
+	return tt.title, tt.params, ok
+}
+
+// Layer returns a render layer drawing text in the style the user picked for
+// a context. If no parameters have been seen yet the text is drawn with the
+// application's defaults. Use this, rather than events.NewESSetTitle, for
+// text that must be laid out predictably, as the application clips long
+// titles.
+func (t *TitleTracker) Layer(context string, state int, text string) render.Title {
+	_, params, ok := t.Get(context, state)
+	if !ok {
+		params = events.TitleParameters{
+			FontSize:       12,
+			ShowTitle:      true,
+			TitleAlignment: "bottom",
+			TitleColor:     "#ffffff",
+		}
+	}
+	return render.Title{Text: text, Params: params, Padding: 4}
+}
@@ -0,0 +1,50 @@
+package streamdeck
+
+import (
+	"testing"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+func titleChange(context string, state int, title string, size int) events.ERTitleParametersDidChange {
+	e := events.ERTitleParametersDidChange{ERCommon: events.ERCommon{Action: "com.example.title", Context: context}}
+	e.Payload.State = state
+	e.Payload.Title = title
+	e.Payload.TitleParameters = events.TitleParameters{FontSize: size, ShowTitle: true, TitleAlignment: "top", TitleColor: "#ff0000"}
+	return e
+}
+
+func TestTitleTracker(t *testing.T) {
+	c := New()
+	tt := NewTitleTracker(&c)
+
+	c.handle(titleChange("A", 0, "off", 10))
+	c.handle(titleChange("A", 1, "on", 20))
+	c.handle(titleChange("B", 0, "other", 30))
+	c.handle(titleChange("A", 1, "ON", 22))
+
+	if title, params, ok := tt.Get("A", 0); !ok || title != "off" || params.FontSize != 10 {
+		t.Errorf("wrong title for state 0: %q %+v %v", title, params, ok)
+	}
+	if title, params, ok := tt.Get("A", 1); !ok || title != "ON" || params.FontSize != 22 {
+		t.Errorf("expected the latest title for state 1: %q %+v %v", title, params, ok)
+	}
+	if l := tt.Layer("A", 1, "text"); l.Text != "text" || l.Params.TitleAlignment != "top" || l.Params.FontSize != 22 {
+		t.Errorf("expected the layer to use the user's parameters, got %+v", l)
+	}
+
+	c.handle(events.ERWillDisappear{ERCommon: events.ERCommon{Action: "com.example.title", Context: "A"}})
+	for _, state := range []int{0, 1} {
+		if _, _, ok := tt.Get("A", state); ok {
+			t.Errorf("expected state %d to be forgotten on willDisappear", state)
+		}
+	}
+	if _, _, ok := tt.Get("B", 0); !ok {
+		t.Error("expected other contexts to be kept")
+	}
+
+	l := tt.Layer("A", 0, "text")
+	if l.Params.FontSize != 12 || !l.Params.ShowTitle || l.Params.TitleAlignment != "bottom" || l.Params.TitleColor != "#ffffff" {
+		t.Errorf("expected the default parameters, got %+v", l.Params)
+	}
+}
@@ -0,0 +1,186 @@
+package tools
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// PayloadCache remembers encoded image payloads, so that images which are
+// shown over and over (on/off, muted/unmuted) are only encoded once. Entries
+// are found either by a hash of the image itself or by a key chosen by the
+// caller. When the total size of the cached payloads exceeds the limit the
+// least recently used are evicted.
+//
+// A PayloadCache is safe for concurrent use.
+type PayloadCache struct {
+	opts     EncodeOptions
+	maxBytes int
+
+	mu     sync.Mutex
+	lru    *list.List // of *cacheEntry, most recently used at the front
+	items  map[string]*list.Element
+	bytes  int
+	hits   int
+	misses int
+}
+
+type cacheEntry struct {
+	key     string
+	payload string
+}
+
+// CacheStats is a snapshot of a PayloadCache's counters.
+type CacheStats struct {
+	Hits    int
+	Misses  int
+	Entries int
+	Bytes   int // total length of the cached payloads
+}
+
+// NewPayloadCache creates a cache holding up to maxBytes of payloads. Images
+// are encoded with opts.
+func NewPayloadCache(maxBytes int, opts EncodeOptions) *PayloadCache {
+	return &PayloadCache{
+		opts:     opts,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Encode returns the payload for img, encoding it only if an identical image
+// has not been seen before.
+func (c *PayloadCache) Encode(img image.Image) (string, error) {
+	return c.EncodeKey(c.hash(img), img)
+}
+
+// EncodeKey returns the payload cached under key, or encodes img and caches
+// it under key if there is none. img is not looked at on a hit, so a caller
+// who names their images can skip rendering entirely by checking Get first.
+func (c *PayloadCache) EncodeKey(key string, img image.Image) (string, error) {
+	if p, ok := c.Get(key); ok {
+		return p, nil
+	}
+	p, err := Encode(img, c.opts)
+	if err != nil {
+		return "", err
+	}
+	c.Put(key, p)
+	return p, nil
+}
+
+// Get returns the payload cached under key.
+func (c *PayloadCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return "", false
+	}
+	c.hits++
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry).payload, true
+}
+
+// Put caches an already encoded payload under key.
+func (c *PayloadCache) Put(key string, payload string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*cacheEntry)
+		c.bytes += len(payload) - len(e.payload)
+		e.payload = payload
+		c.lru.MoveToFront(el)
+	} else {
+		c.items[key] = c.lru.PushFront(&cacheEntry{key: key, payload: payload})
+		c.bytes += len(payload)
+	}
+	// always keep the newest entry, even if it alone is over the limit
+	for c.bytes > c.maxBytes && c.lru.Len() > 1 {
+		el := c.lru.Back()
+		e := el.Value.(*cacheEntry)
+		c.lru.Remove(el)
+		delete(c.items, e.key)
+		c.bytes -= len(e.payload)
+	}
+}
+
+// Preload caches every file in fsys matching any of the glob patterns,
+// keyed by its path. PNG, JPEG and GIF files are used as they are, without
+// decoding or re-encoding, and SVG files are checked with EncodeSVG.
+func (c *PayloadCache) Preload(fsys fs.FS, patterns ...string) error {
+	for _, pattern := range patterns {
+		names, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			b, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return err
+			}
+			var p string
+			switch strings.ToLower(path.Ext(name)) {
+			case ".png":
+				p = DataURL(FormatPNG.MIMEType(), b)
+			case ".jpg", ".jpeg":
+				p = DataURL(FormatJPEG.MIMEType(), b)
+			case ".gif":
+				p = DataURL(FormatGIF.MIMEType(), b)
+			case ".svg":
+				p, err = EncodeSVG(string(b))
+				if err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			default:
+				return fmt.Errorf("%s: unsupported image type", name)
+			}
+			c.Put(name, p)
+		}
+	}
+	return nil
+}
+
+// Stats returns the current counters.
+func (c *PayloadCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Hits:    c.hits,
+		Misses:  c.misses,
+		Entries: c.lru.Len(),
+		Bytes:   c.bytes,
+	}
+}
+
+// hash returns a key identifying the content of img. Two images with the
+// same size and pixels have the same key, whatever their concrete type.
+func (c *PayloadCache) hash(img image.Image) string {
+	h := sha256.New()
+	b := img.Bounds()
+	binary.Write(h, binary.LittleEndian, [2]int64{int64(b.Dx()), int64(b.Dy())})
+
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Stride == 4*b.Dx() {
+		h.Write(rgba.Pix[:4*b.Dx()*b.Dy()])
+	} else {
+		px := make([]byte, 4)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, bl, a := img.At(x, y).RGBA()
+				// match the 8 bit values *image.RGBA hashes
+				px[0], px[1], px[2], px[3] = byte(r>>8), byte(g>>8), byte(bl>>8), byte(a>>8)
+				h.Write(px)
+			}
+		}
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
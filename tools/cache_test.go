@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPayloadCache(t *testing.T) {
+	c := NewPayloadCache(1<<20, EncodeOptions{})
+
+	a := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	a.Set(0, 0, color.White)
+	// same pixels, different type
+	b := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	b.Set(0, 0, color.White)
+
+	p1, err := c.Encode(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := c.Encode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p1 != p2 {
+		t.Error("identical images were cached separately")
+	}
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 1 || s.Entries != 1 {
+		t.Errorf("wrong stats %+v", s)
+	}
+
+	err = c.Preload(fstest.MapFS{"icons/on.svg": {Data: []byte("<svg></svg>")}}, "icons/*.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("icons/on.svg"); !ok {
+		t.Error("preloaded icon missing")
+	}
+
+	// a cache too small for two entries keeps only the newest
+	small := NewPayloadCache(len(p1)+1, EncodeOptions{})
+	small.Put("one", p1)
+	small.Put("two", p1)
+	if _, ok := small.Get("one"); ok {
+		t.Error("oldest entry was not evicted")
+	}
+	if _, ok := small.Get("two"); !ok {
+		t.Error("newest entry was evicted")
+	}
+}
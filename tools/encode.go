@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Format is an image encoding.
+type Format int
+
+const (
+	FormatPNG = Format(iota)
+	FormatJPEG
+	FormatGIF
+)
+
+// MIMEType returns the MIME type used in data URLs for the format.
+func (f Format) MIMEType() string {
+	switch f {
+	case FormatJPEG:
+		return "image/jpeg"
+	case FormatGIF:
+		return "image/gif"
+	default:
+		return "image/png"
+	}
+}
+
+// EncodeOptions controls how Encode encodes an image.
+type EncodeOptions struct {
+	Format Format
+	// JPEGQuality is from 1 to 100, zero uses jpeg.DefaultQuality.
+	JPEGQuality int
+	// PNGCompression trades size for speed. The zero value is
+	// png.DefaultCompression, png.BestSpeed is a good choice for images
+	// that change frequently.
+	PNGCompression png.CompressionLevel
+}
+
+// buffers holds byte buffers for reuse between encodes.
+var buffers = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// pngBufferPool lets the png encoder reuse its internal buffers.
+type pngBufferPool struct {
+	pool sync.Pool
+}
+
+func (p *pngBufferPool) Get() *png.EncoderBuffer {
+	b, _ := p.pool.Get().(*png.EncoderBuffer)
+	return b
+}
+
+func (p *pngBufferPool) Put(b *png.EncoderBuffer) {
+	p.pool.Put(b)
+}
+
+var pngBuffers = &pngBufferPool{}
+
+// Encode encodes an image into a data URL, suitable for the image of an
+// events.ESSetImage or a pixmap in an events.ESSetFeedback.
+func Encode(img image.Image, opts EncodeOptions) (string, error) {
+	buf := buffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer buffers.Put(buf)
+
+	err := EncodeTo(buf, img, opts)
+	if err != nil {
+		return "", err
+	}
+	return DataURL(opts.Format.MIMEType(), buf.Bytes()), nil
+}
+
+// EncodeTo writes the encoded image to w, without any data URL wrapping.
+func EncodeTo(w io.Writer, img image.Image, opts EncodeOptions) error {
+	switch opts.Format {
+	case FormatPNG:
+		enc := png.Encoder{
+			CompressionLevel: opts.PNGCompression,
+			BufferPool:       pngBuffers,
+		}
+		return enc.Encode(w, img)
+	case FormatJPEG:
+		q := opts.JPEGQuality
+		if q == 0 {
+			q = jpeg.DefaultQuality
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: q})
+	case FormatGIF:
+		return gif.Encode(w, img, nil)
+	}
+	return fmt.Errorf("unknown image format %d", opts.Format)
+}
+
+// EncodePNG encodes an image as a PNG data URL.
+func EncodePNG(img image.Image, level png.CompressionLevel) (string, error) {
+	return Encode(img, EncodeOptions{Format: FormatPNG, PNGCompression: level})
+}
+
+// EncodeJPEG encodes an image as a JPEG data URL, with quality from 1 to 100.
+func EncodeJPEG(img image.Image, quality int) (string, error) {
+	return Encode(img, EncodeOptions{Format: FormatJPEG, JPEGQuality: quality})
+}
+
+// EncodeGIF encodes an image as a GIF data URL. Images with more than 256
+// colours are reduced to the Plan 9 palette.
+func EncodeGIF(img image.Image) (string, error) {
+	return Encode(img, EncodeOptions{Format: FormatGIF})
+}
+
+// EncodeSVG checks that svg is a well formed SVG document and returns it as
+// a data URL.
+func EncodeSVG(svg string) (string, error) {
+	d := xml.NewDecoder(strings.NewReader(svg))
+	root := ""
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid svg: %w", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok && root == "" {
+			root = se.Name.Local
+		}
+	}
+	if root != "svg" {
+		return "", errors.New("invalid svg: root element is not <svg>")
+	}
+	return SVGToPayload(svg), nil
+}
+
+// DataURL builds a base64 encoded data URL from raw data.
+func DataURL(mimeType string, data []byte) string {
+	b := strings.Builder{}
+	prefix := "data:" + mimeType + ";base64,"
+	b.Grow(len(prefix) + base64.StdEncoding.EncodedLen(len(data)))
+	b.WriteString(prefix)
+	enc := base64.NewEncoder(base64.StdEncoding, &b)
+	enc.Write(data)
+	enc.Close()
+	return b.String()
+}
+
+// DecodePayload decodes a base64 data URL, such as the image of an
+// events.ESSetImage, back into an image. PNG, JPEG and GIF images are
+// supported, SVG images are not.
+func DecodePayload(payload string) (image.Image, error) {
+	header, data, ok := strings.Cut(payload, ",")
+	if !ok || !strings.HasPrefix(header, "data:image/") {
+		return nil, errors.New("not an image data URL")
+	}
+	if strings.HasPrefix(header, "data:image/svg+xml") {
+		return nil, errors.New("cannot decode svg images")
+	}
+	if !strings.HasSuffix(header, ";base64") {
+		return nil, fmt.Errorf("cannot decode %s, only base64 data URLs are supported", header)
+	}
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 data: %w", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	return img, err
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, color.White)
+
+	for _, tc := range []struct {
+		format Format
+		prefix string
+	}{
+		{FormatPNG, "data:image/png;base64,"},
+		{FormatJPEG, "data:image/jpeg;base64,"},
+		{FormatGIF, "data:image/gif;base64,"},
+	} {
+		p, err := Encode(img, EncodeOptions{Format: tc.format})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(p, tc.prefix) {
+			t.Errorf("expected prefix %s, got %s", tc.prefix, p)
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(p, tc.prefix))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, format, err := image.Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("%s did not decode: %s", tc.prefix, err)
+		} else if "image/"+format != tc.format.MIMEType() {
+			t.Errorf("expected %s, decoded as %s", tc.format.MIMEType(), format)
+		}
+	}
+
+	_, err := Encode(img, EncodeOptions{Format: Format(99)})
+	if err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestDecodePayload(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(1, 1, color.RGBA{R: 0xff, A: 0xff})
+
+	for _, p := range []string{mustEncode(t, img), ImageToPayload(img)} {
+		got, err := DecodePayload(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Bounds() != img.Bounds() {
+			t.Errorf("wrong bounds %v", got.Bounds())
+		}
+		if r, _, _, _ := got.At(1, 1).RGBA(); r != 0xffff {
+			t.Errorf("wrong pixel %v", got.At(1, 1))
+		}
+	}
+
+	for _, bad := range []string{"", "data:text/plain;base64,aGk=", SVGToPayload("<svg/>"), "data:image/png;base64,!!"} {
+		_, err := DecodePayload(bad)
+		if err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func mustEncode(t *testing.T, img image.Image) string {
+	t.Helper()
+	p, err := EncodePNG(img, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestEncodeSVG(t *testing.T) {
+	_, err := EncodeSVG(`<svg xmlns="http://www.w3.org/2000/svg"><rect width="1" height="1"/></svg>`)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, bad := range []string{`<svg><rect></svg>`, `<html></html>`, ``} {
+		_, err := EncodeSVG(bad)
+		if err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
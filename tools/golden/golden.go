@@ -0,0 +1,204 @@
+// Package golden compares rendered images against golden PNG files in tests.
+//
+// Goldens are stored as testdata/<name>.png next to the test. Set Update, or
+// define an -update flag in the test package and run the tests with it, to
+// write the current output as the new goldens:
+//
+//	var update = flag.Bool("update", false, "update golden images")
+//
+//	go test ./mypackage -update
+//
+// When an image does not match, a diff image is written alongside the golden
+// as testdata/<name>.diff.png, showing the golden faded with the differing
+// pixels in red, and the rendered image as testdata/<name>.actual.png.
+package golden
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tardisx/streamdeck-plugin/tools"
+)
+
+// Update makes Assert write the images it is given as the new goldens,
+// rather than comparing them. It is also set by a boolean flag called
+// "update", if the test package defines one.
+var Update = false
+
+// Dir is the directory goldens are kept in, relative to the test.
+var Dir = "testdata"
+
+// maxDelta is the largest value delta can return, for black against white.
+const maxDelta = 35215
+
+// Options controls how closely an image must match its golden.
+type Options struct {
+	// Tolerance is how different a pixel may be before it counts as
+	// changed, from 0 to 1 on a perceptual scale. Small differences from
+	// anti-aliasing are usually below 0.1.
+	Tolerance float64
+	// MaxChanged is the fraction of pixels, from 0 to 1, that may change
+	// before the image fails to match.
+	MaxChanged float64
+}
+
+// DefaultOptions allows for the small differences in anti-aliasing between
+// platforms, while catching changes a person would notice.
+var DefaultOptions = Options{Tolerance: 0.1, MaxChanged: 0.001}
+
+// Assert fails the test if img does not match the golden image called name.
+func Assert(t testing.TB, name string, img image.Image, opts Options) {
+	t.Helper()
+	path := filepath.Join(Dir, name+".png")
+	if updating() {
+		err := writePNG(path, img)
+		if err != nil {
+			t.Fatalf("cannot update golden: %s", err)
+		}
+		t.Logf("updated %s", path)
+		return
+	}
+
+	want, err := readPNG(path)
+	if err != nil {
+		t.Fatalf("cannot read golden, run with -update to create it: %s", err)
+	}
+	changed, diff := Compare(want, img, opts.Tolerance)
+	total := img.Bounds().Dx() * img.Bounds().Dy()
+	if diff != nil && float64(changed) <= opts.MaxChanged*float64(total) {
+		return
+	}
+
+	writePNG(filepath.Join(Dir, name+".actual.png"), img)
+	if diff == nil {
+		t.Errorf("image is %v, golden %s is %v", img.Bounds().Size(), path, want.Bounds().Size())
+		return
+	}
+	diffPath := filepath.Join(Dir, name+".diff.png")
+	err = writePNG(diffPath, diff)
+	if err != nil {
+		t.Errorf("image does not match %s, %d of %d pixels differ", path, changed, total)
+		return
+	}
+	t.Errorf("image does not match %s, %d of %d pixels differ, see %s", path, changed, total, diffPath)
+}
+
+// updating reports whether goldens should be written. The flag is looked up
+// rather than defined here, so that it does not clash with a test package's
+// own -update flag.
+func updating() bool {
+	if Update {
+		return true
+	}
+	f := flag.Lookup("update")
+	if f == nil {
+		return false
+	}
+	g, ok := f.Value.(flag.Getter)
+	if !ok {
+		return false
+	}
+	on, _ := g.Get().(bool)
+	return on
+}
+
+// AssertPayload decodes a data URL, such as the image of an
+// events.ESSetImage, and compares it with the golden image called name.
+func AssertPayload(t testing.TB, name string, payload string, opts Options) {
+	t.Helper()
+	img, err := tools.DecodePayload(payload)
+	if err != nil {
+		t.Fatalf("cannot decode payload: %s", err)
+	}
+	Assert(t, name, img, opts)
+}
+
+// Compare counts the pixels that differ between want and got by more than
+// tolerance, and returns a diff image showing them. If the images are not
+// the same size, every pixel is counted as changed and there is no diff.
+func Compare(want, got image.Image, tolerance float64) (int, *image.RGBA) {
+	wb, gb := want.Bounds(), got.Bounds()
+	if wb.Size() != gb.Size() {
+		return max(wb.Dx()*wb.Dy(), gb.Dx()*gb.Dy()), nil
+	}
+
+	diff := image.NewRGBA(image.Rect(0, 0, wb.Dx(), wb.Dy()))
+	changed := 0
+	for y := 0; y < wb.Dy(); y++ {
+		for x := 0; x < wb.Dx(); x++ {
+			w := want.At(wb.Min.X+x, wb.Min.Y+y)
+			d := delta(w, got.At(gb.Min.X+x, gb.Min.Y+y))
+			// delta is squared, so the tolerance is too
+			if d > tolerance*tolerance*maxDelta {
+				changed++
+				diff.Set(x, y, color.RGBA{R: 0xff, A: 0xff})
+				continue
+			}
+			// fade unchanged pixels so the changes stand out
+			l := uint8(0xff + (luma(w)-0xff)*0.2)
+			diff.Set(x, y, color.RGBA{R: l, G: l, B: l, A: 0xff})
+		}
+	}
+	return changed, diff
+}
+
+// delta is the squared perceptual distance between two colours in the YIQ
+// colour space, as used by pixelmatch. Each is first blended with black, as
+// the Stream Deck shows transparent areas as black.
+func delta(a, b color.Color) float64 {
+	y1, i1, q1 := yiq(a)
+	y2, i2, q2 := yiq(b)
+	dy, di, dq := y1-y2, i1-i2, q1-q2
+	return 0.5053*dy*dy + 0.299*di*di + 0.1957*dq*dq
+}
+
+func yiq(c color.Color) (y, i, q float64) {
+	r, g, b := blend(c)
+	y = r*0.29889531 + g*0.58662247 + b*0.11448223
+	i = r*0.59597799 - g*0.27417610 - b*0.32180189
+	q = r*0.21147017 - g*0.52261711 + b*0.31114694
+	return y, i, q
+}
+
+func luma(c color.Color) float64 {
+	y, _, _ := yiq(c)
+	return y
+}
+
+// blend returns the colour over a black background, from 0 to 255.
+func blend(c color.Color) (r, g, b float64) {
+	// RGBA is alpha-premultiplied, which is the colour over black
+	cr, cg, cb, _ := c.RGBA()
+	return float64(cr) / 0x101, float64(cg) / 0x101, float64(cb) / 0x101
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func writePNG(path string, img image.Image) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(f, img)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
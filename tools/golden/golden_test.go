@@ -0,0 +1,69 @@
+package golden
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(a, a.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	b := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(b, b.Bounds(), a, image.Point{}, draw.Src)
+
+	// a barely visible change is within tolerance
+	b.Set(1, 1, color.RGBA{R: 4, G: 4, B: 4, A: 0xff})
+	changed, diff := Compare(a, b, DefaultOptions.Tolerance)
+	if changed != 0 || diff == nil {
+		t.Errorf("expected no change, got %d", changed)
+	}
+
+	// a mid grey is well beyond it
+	b.Set(4, 4, color.RGBA{R: 0x53, G: 0x53, B: 0x53, A: 0xff})
+	if changed, _ := Compare(a, b, DefaultOptions.Tolerance); changed != 1 {
+		t.Errorf("expected a mid grey to count as changed, got %d", changed)
+	}
+	b.Set(4, 4, color.Black)
+
+	b.Set(2, 2, color.White)
+	b.Set(3, 3, color.RGBA{R: 0xff, A: 0xff})
+	changed, diff = Compare(a, b, DefaultOptions.Tolerance)
+	if changed != 2 {
+		t.Errorf("expected 2 changed pixels, got %d", changed)
+	}
+	if diff.RGBAAt(2, 2) != (color.RGBA{R: 0xff, A: 0xff}) || diff.RGBAAt(1, 1) == diff.RGBAAt(2, 2) {
+		t.Error("diff image does not highlight the changes")
+	}
+
+	// transparent looks black on a key, not white
+	c := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	if changed, _ := Compare(c, a, 0); changed != 0 {
+		t.Errorf("expected transparent to match black, got %d changed", changed)
+	}
+	w := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(w, w.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	if changed, _ := Compare(c, w, DefaultOptions.Tolerance); changed != 100 {
+		t.Errorf("expected transparent not to match white, got %d changed", changed)
+	}
+
+	changed, diff = Compare(a, image.NewRGBA(image.Rect(0, 0, 5, 5)), 0)
+	if changed != 100 || diff != nil {
+		t.Errorf("expected a size mismatch, got %d", changed)
+	}
+}
+
+func TestAssert(t *testing.T) {
+	dir, update := Dir, Update
+	t.Cleanup(func() { Dir, Update = dir, update })
+
+	Dir = t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, color.White)
+
+	Update = true
+	Assert(t, "key", img, DefaultOptions)
+	Update = false
+	Assert(t, "key", img, DefaultOptions)
+}
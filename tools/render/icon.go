@@ -0,0 +1,93 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"  // register decoder
+	_ "image/jpeg" // register decoder
+	_ "image/png"  // register decoder
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	"github.com/tardisx/streamdeck-plugin/tools"
+	xdraw "golang.org/x/image/draw"
+)
+
+// Icon is a layer which draws an image, scaled to fit within Bounds while
+// keeping its aspect ratio, and centred.
+type Icon struct {
+	Image image.Image
+	// Bounds is the area the icon is fitted into. The zero value uses the
+	// whole image, inset by Padding.
+	Bounds image.Rectangle
+	// Padding is the gap left around the icon when Bounds is not set.
+	Padding int
+
+	// svg is kept so the icon can be rasterized at the size it is drawn
+	svg *oksvg.SvgIcon
+}
+
+// IconFromFS loads an icon from a file system, typically an embed.FS. PNG,
+// JPEG and GIF files are decoded directly. Files with a .svg extension are
+// parsed, and rasterized at the size they are drawn so they stay sharp.
+// Unsupported SVG features, such as text and filters, are ignored.
+func IconFromFS(fsys fs.FS, name string) (*Icon, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(path.Ext(name), ".svg") {
+		svg, err := oksvg.ReadIconStream(bytes.NewReader(b), oksvg.IgnoreErrorMode)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %w", name, err)
+		}
+		if svg.ViewBox.W <= 0 || svg.ViewBox.H <= 0 {
+			return nil, fmt.Errorf("cannot parse %s: no size or viewBox", name)
+		}
+		return &Icon{svg: svg}, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode %s: %w", name, err)
+	}
+	return &Icon{Image: img}, nil
+}
+
+func (i *Icon) Draw(dst draw.Image) error {
+	area := i.Bounds
+	if area.Empty() {
+		area = dst.Bounds().Inset(i.Padding)
+	}
+
+	if i.svg != nil {
+		vb := i.svg.ViewBox
+		target := tools.FitRect(image.Pt(int(math.Ceil(vb.W)), int(math.Ceil(vb.H))), area)
+		if target.Empty() {
+			return errors.New("icon has no size")
+		}
+		draw.Draw(dst, target, rasterize(i.svg, target.Dx(), target.Dy()), image.Point{}, draw.Over)
+		return nil
+	}
+	if i.Image == nil {
+		return errors.New("icon has no image")
+	}
+
+	target := tools.FitRect(i.Image.Bounds().Size(), area)
+	xdraw.CatmullRom.Scale(dst, target, i.Image, i.Image.Bounds(), xdraw.Over, nil)
+	return nil
+}
+
+// rasterize draws an SVG icon into a new image of the given size.
+func rasterize(svg *oksvg.SvgIcon, w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	svg.SetTarget(0, 0, float64(w), float64(h))
+	svg.Draw(rasterx.NewDasher(w, h, rasterx.NewScannerGV(w, h, img, img.Bounds())), 1)
+	return img
+}
@@ -0,0 +1,156 @@
+// Package render composes key images from layers, such as a background, an
+// icon and lines of text. Everything is drawn in pure Go, so it works on a
+// headless machine with no system fonts.
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+	"github.com/tardisx/streamdeck-plugin/tools"
+)
+
+// DefaultSize is the width and height of a standard key image at @2x.
+const DefaultSize = 144
+
+// Layer is a single element of an image. Layers are drawn in the order they
+// were added, each on top of the last.
+type Layer interface {
+	Draw(dst draw.Image) error
+}
+
+// Image is a set of layers to be rendered to a fixed size image.
+type Image struct {
+	Width  int
+	Height int
+	layers []Layer
+}
+
+// New creates an empty image of the given size. Use DefaultSize for a
+// standard key.
+func New(width, height int) *Image {
+	return &Image{Width: width, Height: height}
+}
+
+// Add appends layers to the image, returning the image so that calls can be
+// chained.
+func (i *Image) Add(layers ...Layer) *Image {
+	i.layers = append(i.layers, layers...)
+	return i
+}
+
+// Render draws all the layers, in order, onto a transparent image.
+func (i *Image) Render() (*image.RGBA, error) {
+	dst := image.NewRGBA(image.Rect(0, 0, i.Width, i.Height))
+	for _, l := range i.layers {
+		err := l.Draw(dst)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// Payload renders the image and encodes it for an events.ESSetImage.
+func (i *Image) Payload() (string, error) {
+	img, err := i.Render()
+	if err != nil {
+		return "", err
+	}
+	return tools.EncodePNG(img, png.DefaultCompression)
+}
+
+// SetImage renders the image and returns a setImage message ready to be
+// sent.
+func (i *Image) SetImage(context string, target events.EventTarget, state *int) (events.ESSetImage, error) {
+	p, err := i.Payload()
+	if err != nil {
+		return events.ESSetImage{}, err
+	}
+	return events.NewESSetImage(context, p, target, state), nil
+}
+
+// Solid is a layer which fills the whole image with a single colour.
+type Solid struct {
+	Color color.Color
+}
+
+func (s Solid) Draw(dst draw.Image) error {
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(s.Color), image.Point{}, draw.Over)
+	return nil
+}
+
+// Direction is the direction a gradient runs in.
+type Direction int
+
+const (
+	Vertical   = Direction(iota) // From at the top, To at the bottom
+	Horizontal                   // From on the left, To on the right
+	Diagonal                     // From at the top left, To at the bottom right
+)
+
+// Gradient is a layer which fills the whole image with a linear gradient.
+type Gradient struct {
+	From      color.Color
+	To        color.Color
+	Direction Direction
+}
+
+func (g Gradient) Draw(dst draw.Image) error {
+	b := dst.Bounds()
+	from := color.NRGBA64Model.Convert(g.From).(color.NRGBA64)
+	to := color.NRGBA64Model.Convert(g.To).(color.NRGBA64)
+
+	w, h := b.Dx()-1, b.Dy()-1
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var t float64
+			switch g.Direction {
+			case Horizontal:
+				t = ratio(x-b.Min.X, w)
+			case Diagonal:
+				t = ratio(x-b.Min.X+y-b.Min.Y, w+h)
+			default:
+				t = ratio(y-b.Min.Y, h)
+			}
+			c := color.NRGBA64{
+				R: lerp(from.R, to.R, t),
+				G: lerp(from.G, to.G, t),
+				B: lerp(from.B, to.B, t),
+				A: lerp(from.A, to.A, t),
+			}
+			dst.Set(x, y, blend(dst.At(x, y), c))
+		}
+	}
+	return nil
+}
+
+func ratio(n, d int) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+func lerp(a, b uint16, t float64) uint16 {
+	return uint16(float64(a) + (float64(b)-float64(a))*t)
+}
+
+// blend composites src over dst.
+func blend(dst color.Color, src color.Color) color.Color {
+	sr, sg, sb, sa := src.RGBA()
+	if sa == 0xffff {
+		return src
+	}
+	dr, dg, db, da := dst.RGBA()
+	inv := 0xffff - sa
+	return color.RGBA64{
+		R: uint16(sr + dr*inv/0xffff),
+		G: uint16(sg + dg*inv/0xffff),
+		B: uint16(sb + db*inv/0xffff),
+		A: uint16(sa + da*inv/0xffff),
+	}
+}
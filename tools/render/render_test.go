@@ -0,0 +1,204 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+
+	"golang.org/x/image/font"
+)
+
+func TestRender(t *testing.T) {
+	icon := image.NewRGBA(image.Rect(0, 0, 10, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 10; x++ {
+			icon.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	b := bytes.Buffer{}
+	png.Encode(&b, icon)
+	fsys := fstest.MapFS{"icon.png": {Data: b.Bytes()}}
+
+	ic, err := IconFromFS(fsys, "icon.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := New(DefaultSize, DefaultSize).Add(
+		Gradient{From: color.Black, To: color.RGBA{B: 255, A: 255}},
+		ic,
+		Text{Text: "a very long line of text\nsecond", ShrinkToFit: true, VAlign: AlignBottom},
+	).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Bounds().Dx() != DefaultSize || img.Bounds().Dy() != DefaultSize {
+		t.Errorf("wrong size %v", img.Bounds())
+	}
+	// the icon is 1:2, so fitted to the full height it is centred with
+	// the gradient showing either side
+	if c := img.RGBAAt(DefaultSize/2, DefaultSize/2); c.R != 255 || c.B != 0 {
+		t.Errorf("expected icon in the centre, got %v", c)
+	}
+	if c := img.RGBAAt(2, 0); c.R != 0 || c.B != 0 {
+		t.Errorf("expected black at the top left, got %v", c)
+	}
+
+	p, err := New(DefaultSize, DefaultSize).Add(Solid{Color: color.White}).Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(p, "data:image/png;base64,") {
+		t.Errorf("bad payload %s", p)
+	}
+
+}
+
+func TestSVGIcon(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 20"><rect width="10" height="20" fill="#00ff00"/></svg>`
+	fsys := fstest.MapFS{"icon.svg": {Data: []byte(svg)}}
+	ic, err := IconFromFS(fsys, "icon.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := New(DefaultSize, DefaultSize).Add(Solid{Color: color.Black}, ic).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the icon keeps its 1:2 aspect ratio, centred
+	if c := img.RGBAAt(DefaultSize/2, DefaultSize/2); c.G != 255 || c.R != 0 {
+		t.Errorf("expected the icon in the centre, got %v", c)
+	}
+	if c := img.RGBAAt(2, DefaultSize/2); c.G != 0 {
+		t.Errorf("expected the background at the side, got %v", c)
+	}
+
+	if _, err := IconFromFS(fstest.MapFS{"bad.svg": {Data: []byte("not svg")}}, "bad.svg"); err == nil {
+		t.Error("expected an error for invalid SVG")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	face, err := NewFace(Regular(), 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+
+	lines := Wrap(face, "the quick brown fox jumps over the lazy dog", 100, 3)
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %q", lines)
+	}
+	if !strings.HasSuffix(lines[2], ellipsis) {
+		t.Errorf("expected the last line to be cut short, got %q", lines)
+	}
+	for _, l := range lines {
+		if w := font.MeasureString(face, l).Ceil(); w > 100 {
+			t.Errorf("line %q is %d wide", l, w)
+		}
+	}
+
+	lines = Wrap(face, "supercalifragilistic", 60, 0)
+	if len(lines) < 2 || strings.Join(lines, "") != "supercalifragilistic" {
+		t.Errorf("long word not broken cleanly: %q", lines)
+	}
+}
+
+func TestTitle(t *testing.T) {
+	params := func(align string, underline bool) events.TitleParameters {
+		return events.TitleParameters{ShowTitle: true, FontSize: 12, TitleAlignment: align, FontUnderline: underline, TitleColor: "#ff0000"}
+	}
+	// rows returns the first and last rows with anything drawn in red, or
+	// -1 if there are none
+	rows := func(img *image.RGBA) (int, int) {
+		first, last := -1, -1
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if c := img.RGBAAt(x, y); c.R > 0x80 && c.G == 0 {
+					if first < 0 {
+						first = y
+					}
+					last = y
+					break
+				}
+			}
+		}
+		return first, last
+	}
+	third := DefaultSize / 3
+
+	plain := 0
+	for _, test := range []struct {
+		name   string
+		params events.TitleParameters
+		check  func(first, last int) bool
+	}{
+		{"hidden", events.TitleParameters{FontSize: 12, TitleColor: "#ff0000"}, func(first, last int) bool { return first < 0 }},
+		{"top", params("top", false), func(first, last int) bool { return first >= 0 && last < third }},
+		{"middle", params("middle", false), func(first, last int) bool {
+			mid := (first + last) / 2
+			return first > third && last < 2*third && mid >= DefaultSize/2-2 && mid <= DefaultSize/2+2
+		}},
+		{"default", params("", false), func(first, last int) bool { return first > third && last < 2*third }},
+		{"bottom", params("bottom", false), func(first, last int) bool { return first > 2*third }},
+		// underlined text reaches further down than the plain top case
+		{"underline", params("top", true), func(first, last int) bool { return first >= 0 && last > plain }},
+	} {
+		img, err := New(DefaultSize, DefaultSize).Add(
+			Solid{Color: color.Black},
+			Title{Text: "HH", Params: test.params},
+		).Render()
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		first, last := rows(img)
+		if test.name == "top" {
+			plain = last
+		}
+		if !test.check(first, last) {
+			t.Errorf("%s: title drawn in rows %d to %d", test.name, first, last)
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want color.NRGBA
+		err  bool
+	}{
+		{in: "#ffffff", want: color.NRGBA{0xff, 0xff, 0xff, 0xff}},
+		{in: "#FF0000FF", want: color.NRGBA{0xff, 0, 0, 0xff}},
+		{in: "#12345680", want: color.NRGBA{0x12, 0x34, 0x56, 0x80}},
+		{in: "#0f0", want: color.NRGBA{0, 0xff, 0, 0xff}},
+		{in: "", err: true},
+		{in: "ffffff", err: true},
+		{in: "#ffff", err: true},
+		{in: "#gggggg", err: true},
+		{in: "#12345", err: true},
+	} {
+		c, err := ParseColor(test.in)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %v", test.in, c)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", test.in, err)
+			continue
+		}
+		if c != test.want {
+			t.Errorf("%q: expected %v, got %v", test.in, test.want, c)
+		}
+	}
+}
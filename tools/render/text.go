@@ -0,0 +1,212 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// defaultTextSize is used when Text.Size is not set.
+const defaultTextSize = 24
+
+// defaultMinSize is the smallest size text is shrunk to when
+// Text.MinSize is not set.
+const defaultMinSize = 8
+
+// Align is the horizontal alignment of text.
+type Align int
+
+const (
+	AlignCenter = Align(iota)
+	AlignLeft
+	AlignRight
+)
+
+// VAlign is the vertical alignment of a block of text.
+type VAlign int
+
+const (
+	AlignMiddle = VAlign(iota)
+	AlignTop
+	AlignBottom
+)
+
+var (
+	regularOnce, boldOnce, italicOnce, boldItalicOnce sync.Once
+	regular, bold, italic, boldItalic                 *opentype.Font
+)
+
+// Regular returns the Go Regular font, which is built in.
+func Regular() *opentype.Font {
+	regularOnce.Do(func() {
+		regular, _ = opentype.Parse(goregular.TTF)
+	})
+	return regular
+}
+
+// Bold returns the Go Bold font, which is built in.
+func Bold() *opentype.Font {
+	boldOnce.Do(func() {
+		bold, _ = opentype.Parse(gobold.TTF)
+	})
+	return bold
+}
+
+// Italic returns the Go Italic font, which is built in.
+func Italic() *opentype.Font {
+	italicOnce.Do(func() {
+		italic, _ = opentype.Parse(goitalic.TTF)
+	})
+	return italic
+}
+
+// BoldItalic returns the Go Bold Italic font, which is built in.
+func BoldItalic() *opentype.Font {
+	boldItalicOnce.Do(func() {
+		boldItalic, _ = opentype.Parse(gobolditalic.TTF)
+	})
+	return boldItalic
+}
+
+// Text is a layer which draws one or more lines of text. Lines are separated
+// by newlines in Text.
+type Text struct {
+	Text  string
+	Font  *opentype.Font // nil uses Regular
+	Size  float64        // in pixels, defaults to 24
+	Color color.Color    // nil is white
+	// Align and VAlign position the text within Bounds.
+	Align  Align
+	VAlign VAlign
+	// Bounds is the area the text is laid out in. The zero value uses the
+	// whole image, inset by Padding.
+	Bounds  image.Rectangle
+	Padding int
+	// LineSpacing scales the gap between lines, zero means 1.
+	LineSpacing float64
+	// ShrinkToFit reduces the size, down to MinSize, until every line fits
+	// within Bounds. Text which still does not fit is drawn anyway.
+	ShrinkToFit bool
+	MinSize     float64 // defaults to 8
+}
+
+func (t Text) Draw(dst draw.Image) error {
+	area := t.Bounds
+	if area.Empty() {
+		area = dst.Bounds().Inset(t.Padding)
+	}
+	f := t.Font
+	if f == nil {
+		f = Regular()
+	}
+	size := t.Size
+	if size <= 0 {
+		size = defaultTextSize
+	}
+	minSize := t.MinSize
+	if minSize <= 0 {
+		minSize = defaultMinSize
+	}
+	spacing := t.LineSpacing
+	if spacing <= 0 {
+		spacing = 1
+	}
+	c := t.Color
+	if c == nil {
+		c = color.White
+	}
+	lines := strings.Split(t.Text, "\n")
+
+	face, err := NewFace(f, size)
+	if err != nil {
+		return err
+	}
+	for t.ShrinkToFit && size > minSize && !fits(face, lines, spacing, area) {
+		face.Close()
+		size = max(size-1, minSize)
+		face, err = NewFace(f, size)
+		if err != nil {
+			return err
+		}
+	}
+	defer face.Close()
+
+	DrawLines(dst, face, lines, c, t.Align, t.VAlign, spacing, area)
+	return nil
+}
+
+// NewFace returns a face for drawing f at size pixels. Faces are not safe for
+// concurrent use, and should be closed when finished with.
+func NewFace(f *opentype.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+}
+
+// lineHeight returns the distance between baselines of consecutive lines.
+func lineHeight(face font.Face, spacing float64) fixed.Int26_6 {
+	return fixed.Int26_6(float64(face.Metrics().Height) * spacing)
+}
+
+// fits reports whether lines drawn with face fit within area.
+func fits(face font.Face, lines []string, spacing float64, area image.Rectangle) bool {
+	height := lineHeight(face, spacing) * fixed.Int26_6(len(lines))
+	if height.Ceil() > area.Dy() {
+		return false
+	}
+	for _, l := range lines {
+		if font.MeasureString(face, l).Ceil() > area.Dx() {
+			return false
+		}
+	}
+	return true
+}
+
+// DrawLines draws lines of text aligned within area. Lines are not wrapped
+// or clipped.
+func DrawLines(dst draw.Image, face font.Face, lines []string, c color.Color, align Align, valign VAlign, spacing float64, area image.Rectangle) {
+	m := face.Metrics()
+	lh := lineHeight(face, spacing)
+	// the last line only needs its own height, not the gap below it
+	total := lh*fixed.Int26_6(len(lines)-1) + m.Ascent + m.Descent
+
+	top := fixed.I(area.Min.Y)
+	switch valign {
+	case AlignTop:
+	case AlignBottom:
+		top = fixed.I(area.Max.Y) - total
+	default:
+		top += (fixed.I(area.Dy()) - total) / 2
+	}
+
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+	}
+	for i, l := range lines {
+		w := d.MeasureString(l)
+		x := fixed.I(area.Min.X)
+		switch align {
+		case AlignLeft:
+		case AlignRight:
+			x = fixed.I(area.Max.X) - w
+		default:
+			x += (fixed.I(area.Dx()) - w) / 2
+		}
+		d.Dot = fixed.Point26_6{X: x, Y: top + m.Ascent + lh*fixed.Int26_6(i)}
+		d.DrawString(l)
+	}
+}
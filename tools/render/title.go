@@ -0,0 +1,234 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// ellipsis is appended to text which has been cut short.
+const ellipsis = "…"
+
+// titleScale is the key size the Stream Deck application's title font sizes
+// are relative to.
+const titleScale = 72
+
+// defaultMaxLines is used when Title.MaxLines is not set. The Stream Deck
+// application shows at most three lines of title.
+const defaultMaxLines = 3
+
+// Title is a layer which draws text the way the user has asked for titles
+// to be shown, as reported by events.ERTitleParametersDidChange. Long text is
+// wrapped at spaces, and anything that still does not fit is cut short with
+// an ellipsis, so what is drawn is predictable whatever the text.
+type Title struct {
+	Text   string
+	Params events.TitleParameters
+	// Fonts maps the font families the user may pick to fonts. Families not
+	// found here, including every family if Fonts is nil, are drawn with the
+	// built in Go fonts in the requested style.
+	Fonts map[string]*opentype.Font
+	// MaxLines limits the number of lines after wrapping, defaults to 3.
+	MaxLines int
+	// Padding is left around the text, in pixels.
+	Padding int
+}
+
+func (t Title) Draw(dst draw.Image) error {
+	if !t.Params.ShowTitle || t.Text == "" {
+		return nil
+	}
+	area := dst.Bounds().Inset(t.Padding)
+	maxLines := t.MaxLines
+	if maxLines <= 0 {
+		maxLines = defaultMaxLines
+	}
+
+	f, ok := t.Fonts[t.Params.FontFamily]
+	if !ok {
+		f = StyleFont(t.Params.FontStyle)
+	}
+	size := float64(t.Params.FontSize)
+	if size <= 0 {
+		size = 12
+	}
+	// font sizes are relative to a 72 pixel key
+	size = size * float64(dst.Bounds().Dy()) / titleScale
+
+	face, err := NewFace(f, size)
+	if err != nil {
+		return err
+	}
+	defer face.Close()
+
+	c, err := ParseColor(t.Params.TitleColor)
+	if err != nil {
+		c = color.White
+	}
+
+	lines := Wrap(face, t.Text, area.Dx(), maxLines)
+	valign := AlignMiddle
+	switch t.Params.TitleAlignment {
+	case "top":
+		valign = AlignTop
+	case "bottom":
+		valign = AlignBottom
+	}
+	DrawLines(dst, face, lines, c, AlignCenter, valign, 1, area)
+
+	if t.Params.FontUnderline {
+		underline(dst, face, lines, c, valign, area)
+	}
+	return nil
+}
+
+// underline draws a line beneath each of lines, laid out as DrawLines does.
+func underline(dst draw.Image, face font.Face, lines []string, c color.Color, valign VAlign, area image.Rectangle) {
+	m := face.Metrics()
+	lh := lineHeight(face, 1)
+	total := lh*fixed.Int26_6(len(lines)-1) + m.Ascent + m.Descent
+	top := fixed.I(area.Min.Y)
+	switch valign {
+	case AlignTop:
+	case AlignBottom:
+		top = fixed.I(area.Max.Y) - total
+	default:
+		top += (fixed.I(area.Dy()) - total) / 2
+	}
+	thickness := max(1, m.Height.Ceil()/14)
+	for i, l := range lines {
+		w := font.MeasureString(face, l).Ceil()
+		x := area.Min.X + (area.Dx()-w)/2
+		y := (top + m.Ascent + lh*fixed.Int26_6(i)).Ceil() + thickness
+		draw.Draw(dst, image.Rect(x, y, x+w, y+thickness), image.NewUniform(c), image.Point{}, draw.Over)
+	}
+}
+
+// StyleFont returns the built in font for a font style name as used by the
+// Stream Deck application, such as "Bold" or "Bold Italic".
+func StyleFont(style string) *opentype.Font {
+	s := strings.ToLower(style)
+	b := strings.Contains(s, "bold")
+	i := strings.Contains(s, "italic")
+	switch {
+	case b && i:
+		return BoldItalic()
+	case b:
+		return Bold()
+	case i:
+		return Italic()
+	}
+	return Regular()
+}
+
+// Wrap breaks text into lines no wider than width, at spaces where possible
+// and within words where not. Newlines in text always start a new line. If
+// there are more than maxLines lines, the rest is dropped and the last line
+// kept ends with an ellipsis. A maxLines of zero means no limit.
+func Wrap(face font.Face, text string, width int, maxLines int) []string {
+	lines := []string{}
+	for _, para := range strings.Split(text, "\n") {
+		lines = append(lines, wrapParagraph(face, para, width)...)
+	}
+	if maxLines > 0 && len(lines) > maxLines {
+		lines = lines[:maxLines]
+		lines[maxLines-1] = Ellipsize(face, lines[maxLines-1]+ellipsis, width)
+	}
+	return lines
+}
+
+func wrapParagraph(face font.Face, para string, width int) []string {
+	words := strings.Fields(para)
+	if len(words) == 0 {
+		return []string{""}
+	}
+	fitsWidth := func(s string) bool {
+		return font.MeasureString(face, s).Ceil() <= width
+	}
+
+	lines := []string{}
+	current := ""
+	for _, word := range words {
+		candidate := word
+		if current != "" {
+			candidate = current + " " + word
+		}
+		if fitsWidth(candidate) {
+			current = candidate
+			continue
+		}
+		if current != "" {
+			lines = append(lines, current)
+		}
+		// break words too long for a line of their own
+		for !fitsWidth(word) {
+			n := fitRunes(face, word, width)
+			lines = append(lines, word[:n])
+			word = word[n:]
+		}
+		current = word
+	}
+	return append(lines, current)
+}
+
+// fitRunes returns the length in bytes of the longest prefix of s, of at
+// least one rune, that fits in width.
+func fitRunes(face font.Face, s string, width int) int {
+	n := 0
+	for i, r := range s {
+		end := i + len(string(r))
+		if n > 0 && font.MeasureString(face, s[:end]).Ceil() > width {
+			break
+		}
+		n = end
+	}
+	return n
+}
+
+// Ellipsize shortens s to fit in width, ending it with an ellipsis if
+// anything had to be removed. If s already ends with an ellipsis it is kept.
+func Ellipsize(face font.Face, s string, width int) string {
+	if font.MeasureString(face, s).Ceil() <= width {
+		return s
+	}
+	s = strings.TrimSuffix(s, ellipsis)
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := strings.TrimRight(string(runes), " ") + ellipsis
+		if font.MeasureString(face, candidate).Ceil() <= width {
+			return candidate
+		}
+	}
+	return ellipsis
+}
+
+// ParseColor parses a colour as given by the Stream Deck application, such
+// as "#ffffff" or "#FF0000FF".
+func ParseColor(s string) (color.Color, error) {
+	c := color.NRGBA{A: 0xff}
+	var err error
+	switch len(s) {
+	case 7:
+		_, err = fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	case 9:
+		_, err = fmt.Sscanf(s, "#%02x%02x%02x%02x", &c.R, &c.G, &c.B, &c.A)
+	case 4:
+		_, err = fmt.Sscanf(s, "#%1x%1x%1x", &c.R, &c.G, &c.B)
+		c.R, c.G, c.B = c.R*17, c.G*17, c.B*17
+	default:
+		err = fmt.Errorf("invalid colour %q", s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
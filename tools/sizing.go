@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Standard canvas sizes, in pixels.
+var (
+	KeySize       = image.Pt(72, 72)
+	KeySize2x     = image.Pt(144, 144)
+	SegmentSize   = image.Pt(200, 100) // a touch strip segment of a Stream Deck+
+	SegmentSize2x = image.Pt(400, 200)
+)
+
+// nativeKeySizes are the physical key resolutions of each device type.
+var nativeKeySizes = map[int]int{
+	events.DeviceTypeStreamDeck:       72,
+	events.DeviceTypeStreamDeckMini:   80,
+	events.DeviceTypeStreamDeckXL:     96,
+	events.DeviceTypeStreamDeckMobile: 144,
+	events.DeviceTypeCorsairGKeys:     72,
+	events.DeviceTypeCorsairVoyager:   72,
+	events.DeviceTypeStreamDeckPlus:   120,
+	events.DeviceTypeSCUFController:   72,
+	events.DeviceTypeStreamDeckNeo:    96,
+}
+
+// NativeKeySize returns the physical resolution of a key on the given device
+// type, or false for devices without display keys (such as the Pedal) and
+// unknown types.
+func NativeKeySize(deviceType int) (image.Point, bool) {
+	n, ok := nativeKeySizes[deviceType]
+	return image.Pt(n, n), ok
+}
+
+// KeyCanvas returns the size images for keys on the given device type should
+// be rendered at: KeySize where the keys have no more pixels than that, and
+// KeySize2x for everything else, including unknown device types.
+func KeyCanvas(deviceType int) image.Point {
+	n, ok := nativeKeySizes[deviceType]
+	if ok && n <= KeySize.X {
+		return KeySize
+	}
+	return KeySize2x
+}
+
+// ControllerCanvas returns the size images should be rendered at for a
+// controller type, as given in events.ERWillAppear. Encoders draw on a
+// segment of the touch strip, everything else is a key.
+func ControllerCanvas(controller string, hiDPI bool) image.Point {
+	if controller == events.ControllerEncoder {
+		if hiDPI {
+			return SegmentSize2x
+		}
+		return SegmentSize
+	}
+	if hiDPI {
+		return KeySize2x
+	}
+	return KeySize
+}
+
+// Quality selects the interpolation used when scaling images, trading speed
+// for smoothness.
+type Quality int
+
+const (
+	QualityNearest  = Quality(iota) // fastest, blocky
+	QualityApprox                   // approximate bilinear, fast
+	QualityBiLinear                 // smooth
+	QualityBest                     // Catmull-Rom, slowest and sharpest
+)
+
+func (q Quality) scaler() xdraw.Scaler {
+	switch q {
+	case QualityNearest:
+		return xdraw.NearestNeighbor
+	case QualityApprox:
+		return xdraw.ApproxBiLinear
+	case QualityBiLinear:
+		return xdraw.BiLinear
+	default:
+		return xdraw.CatmullRom
+	}
+}
+
+// Resize scales img to exactly size, ignoring its aspect ratio.
+func Resize(img image.Image, size image.Point, q Quality) *image.RGBA {
+	dst := image.NewRGBA(image.Rectangle{Max: size})
+	q.scaler().Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// Fit scales img to fit entirely within size, keeping its aspect ratio. The
+// image is centred and any space around it is transparent.
+func Fit(img image.Image, size image.Point, q Quality) *image.RGBA {
+	dst := image.NewRGBA(image.Rectangle{Max: size})
+	target := FitRect(img.Bounds().Size(), dst.Bounds())
+	if target.Empty() {
+		return dst
+	}
+	q.scaler().Scale(dst, target, img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// Fill scales img to cover all of size, keeping its aspect ratio, and crops
+// whatever overflows equally from both sides.
+func Fill(img image.Image, size image.Point, q Quality) *image.RGBA {
+	dst := image.NewRGBA(image.Rectangle{Max: size})
+	src := FillRect(img.Bounds(), size)
+	if src.Empty() {
+		return dst
+	}
+	q.scaler().Scale(dst, dst.Bounds(), img, src, draw.Src, nil)
+	return dst
+}
+
+// FitRect returns the largest rectangle with the aspect ratio of size that
+// fits within area, centred in it. It is empty if either is empty.
+func FitRect(size image.Point, area image.Rectangle) image.Rectangle {
+	aw, ah := area.Dx(), area.Dy()
+	if size.X <= 0 || size.Y <= 0 || aw <= 0 || ah <= 0 {
+		return image.Rectangle{}
+	}
+	w, h := aw, size.Y*aw/size.X
+	if h > ah {
+		w, h = size.X*ah/size.Y, ah
+	}
+	min := area.Min.Add(image.Pt((aw-w)/2, (ah-h)/2))
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(w, h))}
+}
+
+// FillRect returns the largest part of src with the aspect ratio of size,
+// centred in it. It is empty if either is empty.
+func FillRect(src image.Rectangle, size image.Point) image.Rectangle {
+	sw, sh := src.Dx(), src.Dy()
+	if sw <= 0 || sh <= 0 || size.X <= 0 || size.Y <= 0 {
+		return image.Rectangle{}
+	}
+	cw, ch := sw, sw*size.Y/size.X
+	if ch > sh {
+		cw, ch = sh*size.X/size.Y, sh
+	}
+	min := src.Min.Add(image.Pt((sw-cw)/2, (sh-ch)/2))
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(cw, ch))}
+}
+
+// Crop returns the part of img within r, without scaling. r is in the
+// coordinate space of img.
+func Crop(img image.Image, r image.Rectangle) *image.RGBA {
+	r = r.Intersect(img.Bounds())
+	dst := image.NewRGBA(image.Rectangle{Max: r.Size()})
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst
+}
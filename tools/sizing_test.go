@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+func TestCanvasSizes(t *testing.T) {
+	tests := []struct {
+		device int
+		native image.Point
+		ok     bool
+		canvas image.Point
+	}{
+		{events.DeviceTypeStreamDeck, image.Pt(72, 72), true, KeySize},
+		{events.DeviceTypeStreamDeckMini, image.Pt(80, 80), true, KeySize2x},
+		{events.DeviceTypeStreamDeckXL, image.Pt(96, 96), true, KeySize2x},
+		{events.DeviceTypeStreamDeckPlus, image.Pt(120, 120), true, KeySize2x},
+		{events.DeviceTypeStreamDeckMobile, image.Pt(144, 144), true, KeySize2x},
+		{events.DeviceTypeStreamDeckPedal, image.Point{}, false, KeySize2x},
+		{999, image.Point{}, false, KeySize2x},
+	}
+	for _, tc := range tests {
+		native, ok := NativeKeySize(tc.device)
+		if native != tc.native || ok != tc.ok {
+			t.Errorf("device %d: expected native size %v %v, got %v %v", tc.device, tc.native, tc.ok, native, ok)
+		}
+		if c := KeyCanvas(tc.device); c != tc.canvas {
+			t.Errorf("device %d: expected canvas %v, got %v", tc.device, tc.canvas, c)
+		}
+	}
+
+	controllers := []struct {
+		controller string
+		hiDPI      bool
+		want       image.Point
+	}{
+		{events.ControllerKeypad, false, KeySize},
+		{events.ControllerKeypad, true, KeySize2x},
+		{events.ControllerEncoder, false, SegmentSize},
+		{events.ControllerEncoder, true, SegmentSize2x},
+		{"", false, KeySize},
+	}
+	for _, tc := range controllers {
+		if got := ControllerCanvas(tc.controller, tc.hiDPI); got != tc.want {
+			t.Errorf("%q hiDPI %v: expected %v, got %v", tc.controller, tc.hiDPI, tc.want, got)
+		}
+	}
+}
+
+func TestFitAndFillRect(t *testing.T) {
+	tests := []struct {
+		name string
+		size image.Point
+		area image.Rectangle
+		fit  image.Rectangle
+		fill image.Rectangle
+	}{
+		{"same aspect", image.Pt(10, 10), image.Rect(0, 0, 72, 72), image.Rect(0, 0, 72, 72), image.Rect(0, 0, 10, 10)},
+		{"wide", image.Pt(20, 10), image.Rect(0, 0, 72, 72), image.Rect(0, 18, 72, 54), image.Rect(5, 0, 15, 10)},
+		{"tall", image.Pt(10, 20), image.Rect(0, 0, 72, 72), image.Rect(18, 0, 54, 72), image.Rect(0, 5, 10, 15)},
+		{"offset area", image.Pt(10, 20), image.Rect(10, 10, 30, 30), image.Rect(15, 10, 25, 30), image.Rect(0, 5, 10, 15)},
+		{"empty source", image.Pt(0, 10), image.Rect(0, 0, 72, 72), image.Rectangle{}, image.Rectangle{}},
+		{"empty area", image.Pt(10, 10), image.Rect(0, 0, 0, 72), image.Rectangle{}, image.Rectangle{}},
+	}
+	for _, tc := range tests {
+		if got := FitRect(tc.size, tc.area); got != tc.fit {
+			t.Errorf("%s: expected fit %v, got %v", tc.name, tc.fit, got)
+		}
+		if got := FillRect(image.Rectangle{Max: tc.size}, tc.area.Size()); got != tc.fill {
+			t.Errorf("%s: expected fill %v, got %v", tc.name, tc.fill, got)
+		}
+	}
+}
+
+func TestScaling(t *testing.T) {
+	// red on the left half, blue on the right
+	src := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 10 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	size := image.Pt(10, 10)
+
+	if img := Resize(src, size, QualityNearest); img.Bounds().Size() != size || img.RGBAAt(0, 0).R != 255 || img.RGBAAt(9, 9).B != 255 {
+		t.Error("expected the whole image squeezed in")
+	}
+	fit := Fit(src, size, QualityNearest)
+	if fit.RGBAAt(0, 0).A != 0 || fit.RGBAAt(0, 5).R != 255 || fit.RGBAAt(9, 5).B != 255 {
+		t.Error("expected the image letterboxed")
+	}
+	fill := Fill(src, size, QualityNearest)
+	if fill.RGBAAt(0, 0).R != 255 || fill.RGBAAt(9, 0).B != 255 || fill.RGBAAt(5, 0).A != 255 {
+		t.Error("expected the middle of the image to fill it")
+	}
+	if img := Fill(src, image.Pt(0, 10), QualityNearest); !img.Bounds().Empty() {
+		t.Error("expected an empty image for an empty size")
+	}
+
+	crop := Crop(src, image.Rect(5, 0, 30, 10))
+	if crop.Bounds().Size() != image.Pt(15, 10) || crop.RGBAAt(0, 0).R != 255 || crop.RGBAAt(14, 0).B != 255 {
+		t.Errorf("expected the crop limited to the image, got %v", crop.Bounds())
+	}
+}
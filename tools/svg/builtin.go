@@ -0,0 +1,66 @@
+package svg
+
+// The built in templates are all 144x144, the size of a key at @2x.
+
+// GaugeData fills in the Gauge template.
+type GaugeData struct {
+	Value float64
+	Min   float64
+	Max   float64
+	Label string // shown beneath the value
+	Units string // appended to the value, such as "%"
+	Theme Theme
+}
+
+// Gauge is a 270 degree radial gauge, with the value in the middle.
+var Gauge = Must(Parse("gauge", `{{$t := .Theme.WithDefaults -}}
+<svg xmlns="http://www.w3.org/2000/svg" width="144" height="144" viewBox="0 0 144 144">
+<rect width="144" height="144" fill="{{$t.Background}}"/>
+<path d="{{arc 72 76 52 135 405}}" fill="none" stroke="{{$t.Muted}}" stroke-width="12" stroke-linecap="round"/>
+{{- $f := fraction .Value .Min .Max}}{{if gt $f 0.0}}
+<path d="{{arc 72 76 52 135 (add 135 (mul 270 $f))}}" fill="none" stroke="{{$t.Accent}}" stroke-width="12" stroke-linecap="round"/>
+{{- end}}
+<text x="72" y="86" fill="{{$t.Foreground}}" font-family="sans-serif" font-size="30" font-weight="bold" text-anchor="middle">{{printf "%.0f" .Value}}{{.Units}}</text>
+<text x="72" y="134" fill="{{$t.Foreground}}" font-family="sans-serif" font-size="18" text-anchor="middle">{{.Label}}</text>
+</svg>`))
+
+// ProgressData fills in the ProgressRing template.
+type ProgressData struct {
+	Value float64 // from 0 to 1
+	Label string  // shown in the middle, if empty the percentage is shown
+	Theme Theme
+}
+
+// ProgressRing is a ring which fills clockwise from the top.
+var ProgressRing = Must(Parse("progress", `{{$t := .Theme.WithDefaults -}}
+<svg xmlns="http://www.w3.org/2000/svg" width="144" height="144" viewBox="0 0 144 144">
+<rect width="144" height="144" fill="{{$t.Background}}"/>
+<circle cx="72" cy="72" r="54" fill="none" stroke="{{$t.Muted}}" stroke-width="14"/>
+{{- $f := fraction .Value 0 1}}{{if gt $f 0.0}}
+<path d="{{arc 72 72 54 -90 (add -90 (mul 360 $f))}}" fill="none" stroke="{{$t.Accent}}" stroke-width="14"/>
+{{- end}}
+<text x="72" y="82" fill="{{$t.Foreground}}" font-family="sans-serif" font-size="28" font-weight="bold" text-anchor="middle">
+{{- if .Label}}{{.Label}}{{else}}{{printf "%.0f" (mul $f 100)}}%{{end -}}
+</text>
+</svg>`))
+
+// BadgeData fills in the Badge template.
+type BadgeData struct {
+	Count int    // the badge is hidden when zero
+	Label string // shown in the middle of the key
+	Theme Theme
+}
+
+// Badge is a label with a notification style counter in the top right
+// corner. Counts over 99 are shown as 99+.
+var Badge = Must(Parse("badge", `{{$t := .Theme.WithDefaults -}}
+<svg xmlns="http://www.w3.org/2000/svg" width="144" height="144" viewBox="0 0 144 144">
+<rect width="144" height="144" fill="{{$t.Background}}"/>
+<text x="72" y="84" fill="{{$t.Foreground}}" font-family="sans-serif" font-size="30" text-anchor="middle">{{.Label}}</text>
+{{- if gt .Count 0}}
+<circle cx="112" cy="32" r="26" fill="{{$t.Alert}}"/>
+<text x="112" y="41" fill="#FFFFFF" font-family="sans-serif" font-size="{{if gt .Count 99}}20{{else}}26{{end}}" font-weight="bold" text-anchor="middle">
+{{- if gt .Count 99}}99+{{else}}{{.Count}}{{end -}}
+</text>
+{{- end}}
+</svg>`))
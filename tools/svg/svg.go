@@ -0,0 +1,206 @@
+// Package svg provides SVG templates for key images. Templates use the
+// text/template syntax, and every value they output is XML escaped, so that
+// titles and labels can never break the document.
+package svg
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+	"github.com/tardisx/streamdeck-plugin/tools"
+)
+
+// escapeFunc is the name of the function appended to every action.
+const escapeFunc = "_xmlescape"
+
+// Template is a parsed SVG template.
+type Template struct {
+	t *template.Template
+}
+
+// Funcs are available to every template, in addition to the text/template
+// builtins.
+var Funcs = template.FuncMap{
+	"add":      func(a, b float64) float64 { return a + b },
+	"sub":      func(a, b float64) float64 { return a - b },
+	"mul":      func(a, b float64) float64 { return a * b },
+	"div":      div,
+	"fraction": Fraction,
+	"arc":      Arc,
+}
+
+// Parse parses an SVG template.
+func Parse(name string, src string) (*Template, error) {
+	t := template.New(name).Funcs(Funcs).Funcs(template.FuncMap{escapeFunc: escape})
+	t, err := t.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range t.Templates() {
+		if sub.Tree != nil {
+			escapeActions(sub.Tree, sub.Tree.Root)
+		}
+	}
+	return &Template{t: t}, nil
+}
+
+// Must panics if err is not nil. It is intended for templates parsed into
+// package level variables.
+func Must(t *Template, err error) *Template {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Execute fills in the template with data, returning the SVG document.
+func (t *Template) Execute(data any) (string, error) {
+	b := strings.Builder{}
+	err := t.t.Execute(&b, data)
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Payload fills in the template and returns it as a payload for an
+// events.ESSetImage. The result is checked to be a well formed SVG document.
+func (t *Template) Payload(data any) (string, error) {
+	s, err := t.Execute(data)
+	if err != nil {
+		return "", err
+	}
+	return tools.EncodeSVG(s)
+}
+
+// escapeActions appends the escape function to the pipeline of every action
+// which outputs something, much as html/template does.
+func escapeActions(tree *parse.Tree, n parse.Node) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, sub := range n.Nodes {
+			escapeActions(tree, sub)
+		}
+	case *parse.ActionNode:
+		// actions which only declare or assign variables output nothing
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(escapeFunc).SetTree(tree).SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	case *parse.RangeNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	case *parse.WithNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	}
+}
+
+func escape(v any) string {
+	b := strings.Builder{}
+	xml.EscapeText(&b, []byte(fmt.Sprint(v)))
+	return b.String()
+}
+
+func div(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+// Fraction returns where v lies between min and max, from 0 to 1.
+func Fraction(v, min, max float64) float64 {
+	return tools.Fraction(v, min, max)
+}
+
+// Arc returns SVG path data for a circular arc centred on cx,cy with radius
+// r, running clockwise from angle start to end. Angles are in degrees, with
+// 0 pointing right and 90 pointing down.
+func Arc(cx, cy, r, start, end float64) string {
+	if end-start >= 360 {
+		// a full circle can't be drawn as one arc
+		end = start + 359.99
+	}
+	x1, y1 := pointOn(cx, cy, r, start)
+	x2, y2 := pointOn(cx, cy, r, end)
+	large := 0
+	if end-start > 180 {
+		large = 1
+	}
+	return fmt.Sprintf("M %.2f %.2f A %.2f %.2f 0 %d 1 %.2f %.2f", x1, y1, r, r, large, x2, y2)
+}
+
+func pointOn(cx, cy, r, deg float64) (float64, float64) {
+	rad := deg * math.Pi / 180
+	return cx + r*math.Cos(rad), cy + r*math.Sin(rad)
+}
+
+// Theme holds the colours used by the built in templates.
+type Theme struct {
+	Background string
+	Foreground string
+	Accent     string // highlights, such as the filled part of a gauge
+	Muted      string // the unfilled part of a gauge or ring
+	Alert      string // badges
+}
+
+// DefaultTheme is used for any colour a Theme does not set.
+var DefaultTheme = Theme{
+	Background: "#000000",
+	Foreground: "#FFFFFF",
+	Accent:     "#0099FF",
+	Muted:      "#444444",
+	Alert:      "#E0312B",
+}
+
+// ThemeFromColors builds a theme from the user's colour preferences, as found
+// in the application info (see streamdeck.ApplicationInfo). Colours which
+// are not set are taken from DefaultTheme.
+func ThemeFromColors(c events.InfoColors) Theme {
+	return Theme{
+		Background: DefaultTheme.Background,
+		Foreground: color(c.ButtonPressedTextColor, DefaultTheme.Foreground),
+		Accent:     color(c.HighlightColor, DefaultTheme.Accent),
+		Muted:      color(c.DisabledColor, DefaultTheme.Muted),
+		Alert:      DefaultTheme.Alert,
+	}
+}
+
+// WithDefaults returns the theme with any unset colours taken from
+// DefaultTheme. Templates can use it as {{$t := .Theme.WithDefaults}}.
+func (t Theme) WithDefaults() Theme {
+	t.Background = color(t.Background, DefaultTheme.Background)
+	t.Foreground = color(t.Foreground, DefaultTheme.Foreground)
+	t.Accent = color(t.Accent, DefaultTheme.Accent)
+	t.Muted = color(t.Muted, DefaultTheme.Muted)
+	t.Alert = color(t.Alert, DefaultTheme.Alert)
+	return t
+}
+
+// color returns c as #RRGGBB, or def if c is empty. The application sends
+// #RRGGBBAA, which not every SVG renderer understands.
+func color(c string, def string) string {
+	if c == "" {
+		return def
+	}
+	if len(c) == 9 && c[0] == '#' {
+		return c[:7]
+	}
+	return c
+}
@@ -0,0 +1,52 @@
+package svg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+)
+
+func TestEscaping(t *testing.T) {
+	tmpl, err := Parse("test", `<svg><text fill="{{.Color}}">{{.Text}}</text>{{$x := .Text}}{{range .List}}{{.}}{{end}}</svg>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tmpl.Execute(map[string]any{
+		"Color": `red" onload="alert(1)`,
+		"Text":  "<b>Tom & Jerry</b>",
+		"List":  []string{"<"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<svg><text fill="red&#34; onload=&#34;alert(1)">&lt;b&gt;Tom &amp; Jerry&lt;/b&gt;</text>&lt;</svg>`
+	if out != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out)
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	theme := ThemeFromColors(events.InfoColors{HighlightColor: "#FF0000FF"})
+	for name, tc := range map[string]struct {
+		tmpl *Template
+		data any
+		want string
+	}{
+		"gauge":    {Gauge, GaugeData{Value: 50, Max: 100, Units: "%", Label: "CPU", Theme: theme}, "50%"},
+		"progress": {ProgressRing, ProgressData{Value: 0.25, Theme: theme}, "25%"},
+		"badge":    {Badge, BadgeData{Count: 150, Label: "Mail"}, "99+"},
+	} {
+		out, err := tc.tmpl.Execute(tc.data)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if !strings.Contains(out, tc.want) {
+			t.Errorf("%s: expected %q in\n%s", name, tc.want, out)
+		}
+		_, err = tc.tmpl.Payload(tc.data)
+		if err != nil {
+			t.Errorf("%s: not valid svg: %s", name, err)
+		}
+	}
+}
@@ -7,23 +7,35 @@ import (
 	"encoding/base64"
 	"image"
 	"image/png"
+	"math"
 )
 
 // Turns an image.Image into a string suitable for delivering
-// via an events.ESSetImage struct
+// via an events.ESSetImage struct. It panics if the image cannot
+// be encoded, see Encode for a version which returns an error.
 func ImageToPayload(i image.Image) string {
 
 	out := bytes.Buffer{}
-	b64 := base64.NewEncoder(base64.RawStdEncoding, &out)
+	b64 := base64.NewEncoder(base64.StdEncoding, &out)
 	err := png.Encode(b64, i)
 	if err != nil {
 		panic(err)
 	}
+	// flush the final partial block
+	b64.Close()
 	return "data:image/png;base64," + out.String()
 }
 
 // SVGToPayload create the string necessary to send an SVG
-// via a ESSetImage struct
+// via a ESSetImage struct. The SVG is not checked, see EncodeSVG.
 func SVGToPayload(svg string) string {
 	return "data:image/svg+xml;charset=utf8;base64," + base64.StdEncoding.EncodeToString([]byte(svg))
 }
+
+// Fraction returns where v lies between min and max, from 0 to 1.
+func Fraction(v, min, max float64) float64 {
+	if max <= min {
+		return 0
+	}
+	return math.Max(0, math.Min(1, (v-min)/(max-min)))
+}
@@ -0,0 +1,101 @@
+package widget
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/vector"
+)
+
+// canvas draws anti-aliased shapes onto an image. Coordinates are relative to
+// the top left of the image's bounds.
+type canvas struct {
+	dst    draw.Image
+	bounds image.Rectangle
+	z      *vector.Rasterizer
+}
+
+func newCanvas(dst draw.Image) *canvas {
+	b := dst.Bounds()
+	return &canvas{dst: dst, bounds: b, z: vector.NewRasterizer(b.Dx(), b.Dy())}
+}
+
+// fill draws the current path in c and starts a new one.
+func (cv *canvas) fill(c color.Color) {
+	cv.z.Draw(cv.dst, cv.bounds, image.NewUniform(c), image.Point{})
+	cv.z.Reset(cv.bounds.Dx(), cv.bounds.Dy())
+}
+
+func (cv *canvas) polygon(pts [][2]float64) {
+	if len(pts) < 3 {
+		return
+	}
+	cv.z.MoveTo(float32(pts[0][0]), float32(pts[0][1]))
+	for _, p := range pts[1:] {
+		cv.z.LineTo(float32(p[0]), float32(p[1]))
+	}
+	cv.z.ClosePath()
+}
+
+func (cv *canvas) rect(x0, y0, x1, y1 float64, c color.Color) {
+	cv.polygon([][2]float64{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}})
+	cv.fill(c)
+}
+
+func (cv *canvas) circle(cx, cy, r float64, c color.Color) {
+	cv.polygon(arcPoints(cx, cy, r, 0, 360))
+	cv.fill(c)
+}
+
+// arc draws a thick arc, clockwise from angle start to end in degrees, with
+// 0 pointing right and 90 pointing down.
+func (cv *canvas) arc(cx, cy, r, thickness, start, end float64, c color.Color) {
+	if end <= start {
+		return
+	}
+	outer := arcPoints(cx, cy, r+thickness/2, start, end)
+	inner := arcPoints(cx, cy, r-thickness/2, start, end)
+	for i := len(inner) - 1; i >= 0; i-- {
+		outer = append(outer, inner[i])
+	}
+	cv.polygon(outer)
+	cv.fill(c)
+}
+
+// polyline draws a line of the given width through pts.
+func (cv *canvas) polyline(pts [][2]float64, width float64, c color.Color) {
+	for i := 1; i < len(pts); i++ {
+		x0, y0 := pts[i-1][0], pts[i-1][1]
+		x1, y1 := pts[i][0], pts[i][1]
+		dx, dy := x1-x0, y1-y0
+		l := math.Hypot(dx, dy)
+		if l == 0 {
+			continue
+		}
+		nx, ny := -dy/l*width/2, dx/l*width/2
+		// every quad has the same winding, so overlaps at the joints add
+		// up rather than cancelling out
+		cv.polygon([][2]float64{{x0 + nx, y0 + ny}, {x1 + nx, y1 + ny}, {x1 - nx, y1 - ny}, {x0 - nx, y0 - ny}})
+	}
+	cv.fill(c)
+	// round the joints
+	if width > 2 {
+		for _, p := range pts {
+			cv.polygon(arcPoints(p[0], p[1], width/2, 0, 360))
+		}
+		cv.fill(c)
+	}
+}
+
+// arcPoints returns points along an arc, roughly one per 3 degrees.
+func arcPoints(cx, cy, r, start, end float64) [][2]float64 {
+	steps := int(math.Ceil((end-start)/3)) + 1
+	pts := make([][2]float64, 0, steps+1)
+	for i := 0; i <= steps; i++ {
+		a := (start + (end-start)*float64(i)/float64(steps)) * math.Pi / 180
+		pts = append(pts, [2]float64{cx + r*math.Cos(a), cy + r*math.Sin(a)})
+	}
+	return pts
+}
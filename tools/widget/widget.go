@@ -0,0 +1,122 @@
+// Package widget renders common displays, such as gauges and history
+// charts, as key images or Stream Deck+ touch strip pixmaps. Widgets are
+// render.Layers, so they can be combined with backgrounds, icons and text
+// from the render package.
+package widget
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+	"github.com/tardisx/streamdeck-plugin/tools"
+	"github.com/tardisx/streamdeck-plugin/tools/render"
+
+	"golang.org/x/image/font/opentype"
+)
+
+// Style holds the colours and font shared by the widgets. Any field left
+// unset is taken from DefaultStyle.
+type Style struct {
+	Background color.Color // nil fields are taken from DefaultStyle
+	Foreground color.Color // text
+	Fill       color.Color // the value
+	Track      color.Color // the range the value moves in
+	Alert      color.Color // badges
+	Font       *opentype.Font
+}
+
+// DefaultStyle is used for anything a Style does not set. It matches the
+// default theme of the svg package.
+var DefaultStyle = Style{
+	Background: color.Black,
+	Foreground: color.White,
+	Fill:       color.RGBA{R: 0x00, G: 0x99, B: 0xff, A: 0xff},
+	Track:      color.RGBA{R: 0x44, G: 0x44, B: 0x44, A: 0xff},
+	Alert:      color.RGBA{R: 0xe0, G: 0x31, B: 0x2b, A: 0xff},
+}
+
+func (s Style) withDefaults() Style {
+	if s.Background == nil {
+		s.Background = DefaultStyle.Background
+	}
+	if s.Foreground == nil {
+		s.Foreground = DefaultStyle.Foreground
+	}
+	if s.Fill == nil {
+		s.Fill = DefaultStyle.Fill
+	}
+	if s.Track == nil {
+		s.Track = DefaultStyle.Track
+	}
+	if s.Alert == nil {
+		s.Alert = DefaultStyle.Alert
+	}
+	if s.Font == nil {
+		s.Font = DefaultStyle.Font
+	}
+	return s
+}
+
+// text draws a single line of text, shrinking it to fit r.
+func (s Style) text(dst draw.Image, str string, r image.Rectangle, size float64, align render.Align, c color.Color) error {
+	if str == "" {
+		return nil
+	}
+	return render.Text{
+		Text:        str,
+		Font:        s.Font,
+		Size:        size,
+		Color:       c,
+		Align:       align,
+		Bounds:      r,
+		ShrinkToFit: true,
+	}.Draw(dst)
+}
+
+// Key renders a widget as a key image payload, for events.ESSetImage.
+func Key(w render.Layer) (string, error) {
+	return Render(w, tools.KeySize2x)
+}
+
+// Render renders a widget at the given size and encodes it as a PNG
+// payload. See tools.ControllerCanvas for the right size for a context.
+func Render(w render.Layer, size image.Point) (string, error) {
+	img, err := render.New(size.X, size.Y).Add(w).Render()
+	if err != nil {
+		return "", err
+	}
+	return tools.EncodePNG(img, png.BestSpeed)
+}
+
+// Feedback renders a widget at the size of a touch strip segment and
+// returns a setFeedback message setting the pixmap item key of the current
+// layout to it.
+func Feedback(context string, key string, w render.Layer) (events.ESSetFeedback, error) {
+	p, err := Render(w, tools.SegmentSize)
+	if err != nil {
+		return events.ESSetFeedback{}, err
+	}
+	payload, err := json.Marshal(map[string]string{key: p})
+	if err != nil {
+		return events.ESSetFeedback{}, err
+	}
+	return events.NewESSetFeedback(context, payload), nil
+}
+
+// formatValue formats a value with as few decimal places as make sense for
+// the size of the range.
+func formatValue(v float64, min float64, max float64) string {
+	places := 0
+	if r := max - min; r > 0 && r < 10 {
+		places = 1
+	}
+	if r := max - min; r > 0 && r < 1 {
+		places = 2
+	}
+	return strconv.FormatFloat(v, 'f', places, 64)
+}
@@ -0,0 +1,76 @@
+package widget
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/tardisx/streamdeck-plugin/tools/render"
+)
+
+func TestWidgets(t *testing.T) {
+	h := NewHistory(3)
+	for _, v := range []float64{1, 5, 2, 8} {
+		h.Push(v)
+	}
+	if v := h.Values(); len(v) != 3 || v[0] != 5 || v[2] != 8 {
+		t.Errorf("wrong history %v", v)
+	}
+	h0 := NewHistory(0)
+	h0.Push(1)
+	h0.Push(2)
+	if v := h0.Values(); len(v) != 1 || v[0] != 2 {
+		t.Errorf("expected an empty history to keep one value, got %v", v)
+	}
+	var zero History
+	zero.Push(1)
+	if v := zero.Values(); len(v) != 0 {
+		t.Errorf("expected the zero History to keep nothing, got %v", v)
+	}
+
+	for _, size := range []image.Point{{144, 144}, {200, 100}} {
+		for _, w := range []render.Layer{
+			Gauge{Value: 75, Max: 100, Units: "%", Label: "CPU"},
+			Bar{Value: 3, Max: 10, Label: "Volume"},
+			Sparkline{Values: h.Values(), Label: "Queue"},
+			Badge{Count: 120, Label: "Mail"},
+		} {
+			img, err := render.New(size.X, size.Y).Add(w).Render()
+			if err != nil {
+				t.Fatalf("%T: %s", w, err)
+			}
+			// every widget draws something in the fill or alert colour
+			found := false
+			for i := 0; i < len(img.Pix) && !found; i += 4 {
+				found = img.Pix[i+2] == 0xff && img.Pix[i] == 0 || img.Pix[i] == 0xe0 && img.Pix[i+1] == 0x31
+			}
+			if !found {
+				t.Errorf("%T at %v: nothing drawn in the fill colour", w, size)
+			}
+		}
+	}
+
+	for _, count := range []int{0, -3} {
+		img, err := render.New(144, 144).Add(Badge{Count: count, Label: "Mail"}).Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < len(img.Pix); i += 4 {
+			if img.Pix[i] == 0xe0 && img.Pix[i+1] == 0x31 {
+				t.Errorf("badge drawn for a count of %d", count)
+				break
+			}
+		}
+	}
+
+	fb, err := Feedback("ABC123", "canvas", Bar{Value: 1, Max: 2, Style: Style{Background: color.Transparent}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := map[string]string{}
+	json.Unmarshal(fb.Payload, &payload)
+	if len(payload["canvas"]) == 0 {
+		t.Errorf("no pixmap in feedback %s", fb.Payload)
+	}
+}
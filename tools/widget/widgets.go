@@ -0,0 +1,250 @@
+package widget
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/tardisx/streamdeck-plugin/tools"
+	"github.com/tardisx/streamdeck-plugin/tools/render"
+)
+
+// wide reports whether an area should use the side by side layout of a
+// touch strip segment rather than the stacked layout of a key.
+func wide(b image.Rectangle) bool {
+	return b.Dx()*2 >= b.Dy()*3
+}
+
+// rect builds a rectangle from fractions of b.
+func rect(b image.Rectangle, x0, y0, x1, y1 float64) image.Rectangle {
+	w, h := float64(b.Dx()), float64(b.Dy())
+	return image.Rect(
+		b.Min.X+int(x0*w), b.Min.Y+int(y0*h),
+		b.Min.X+int(x1*w), b.Min.Y+int(y1*h),
+	)
+}
+
+func background(dst draw.Image, s Style) {
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(s.Background), image.Point{}, draw.Over)
+}
+
+// Gauge is a 270 degree radial gauge.
+type Gauge struct {
+	Value float64
+	Min   float64
+	Max   float64
+	Label string
+	Units string // appended to the value, such as "%"
+	Style Style
+}
+
+func (g Gauge) Draw(dst draw.Image) error {
+	s := g.Style.withDefaults()
+	background(dst, s)
+	b := dst.Bounds()
+	w, h := float64(b.Dx()), float64(b.Dy())
+
+	var cx, cy, r float64
+	var valueArea, labelArea image.Rectangle
+	if wide(b) {
+		r = h * 0.36
+		cx, cy = h/2, h/2
+		valueArea = image.Rect(b.Min.X+int(h), b.Min.Y, b.Max.X, b.Min.Y+int(h*0.6))
+		labelArea = image.Rect(b.Min.X+int(h), b.Min.Y+int(h*0.6), b.Max.X, b.Max.Y)
+	} else {
+		r = math.Min(w, h) * 0.36
+		cx, cy = w/2, h*0.46
+		valueArea = image.Rect(b.Min.X+int(cx-r*0.7), b.Min.Y+int(cy-r*0.45), b.Min.X+int(cx+r*0.7), b.Min.Y+int(cy+r*0.45))
+		labelArea = rect(b, 0.05, 0.8, 0.95, 1)
+	}
+	thickness := r * 0.25
+
+	cv := newCanvas(dst)
+	cv.arc(cx, cy, r, thickness, 135, 405, s.Track)
+	cv.arc(cx, cy, r, thickness, 135, 135+270*tools.Fraction(g.Value, g.Min, g.Max), s.Fill)
+
+	err := s.text(dst, formatValue(g.Value, g.Min, g.Max)+g.Units, valueArea, r*0.55, render.AlignCenter, s.Foreground)
+	if err != nil {
+		return err
+	}
+	return s.text(dst, g.Label, labelArea, h*0.14, render.AlignCenter, s.Foreground)
+}
+
+// Bar is a horizontal bar, filled from the left.
+type Bar struct {
+	Value float64
+	Min   float64
+	Max   float64
+	Label string
+	Units string
+	Style Style
+}
+
+func (br Bar) Draw(dst draw.Image) error {
+	s := br.Style.withDefaults()
+	background(dst, s)
+	b := dst.Bounds()
+	h := float64(b.Dy())
+
+	labelArea := rect(b, 0.06, 0.05, 0.94, 0.32)
+	valueArea := rect(b, 0.06, 0.32, 0.94, 0.62)
+	bar := rect(b, 0.08, 0.68, 0.92, 0.82)
+	if wide(b) {
+		labelArea = rect(b, 0.06, 0.08, 0.6, 0.5)
+		valueArea = rect(b, 0.6, 0.08, 0.94, 0.5)
+	}
+
+	cv := newCanvas(dst)
+	x0, y0 := float64(bar.Min.X-b.Min.X), float64(bar.Min.Y-b.Min.Y)
+	x1, y1 := float64(bar.Max.X-b.Min.X), float64(bar.Max.Y-b.Min.Y)
+	cv.rect(x0, y0, x1, y1, s.Track)
+	if f := tools.Fraction(br.Value, br.Min, br.Max); f > 0 {
+		cv.rect(x0, y0, x0+(x1-x0)*f, y1, s.Fill)
+	}
+
+	align := render.AlignCenter
+	if wide(b) {
+		align = render.AlignLeft
+	}
+	err := s.text(dst, br.Label, labelArea, h*0.2, align, s.Foreground)
+	if err != nil {
+		return err
+	}
+	if wide(b) {
+		align = render.AlignRight
+	}
+	return s.text(dst, formatValue(br.Value, br.Min, br.Max)+br.Units, valueArea, h*0.24, align, s.Foreground)
+}
+
+// Sparkline is a line chart of recent values, with the latest value shown
+// above it. See History for keeping the values.
+type Sparkline struct {
+	Values []float64 // oldest first
+	// Min and Max fix the vertical range. If they are equal the range is
+	// taken from Values.
+	Min   float64
+	Max   float64
+	Label string
+	Units string
+	Style Style
+}
+
+func (sl Sparkline) Draw(dst draw.Image) error {
+	s := sl.Style.withDefaults()
+	background(dst, s)
+	b := dst.Bounds()
+	w, h := float64(b.Dx()), float64(b.Dy())
+
+	min, max := sl.Min, sl.Max
+	if min == max && len(sl.Values) > 0 {
+		min, max = sl.Values[0], sl.Values[0]
+		for _, v := range sl.Values {
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+	}
+
+	chart := rect(b, 0.06, 0.34, 0.94, 0.94)
+	if len(sl.Values) > 1 {
+		cx0, cy0 := float64(chart.Min.X-b.Min.X), float64(chart.Min.Y-b.Min.Y)
+		cw, ch := float64(chart.Dx()), float64(chart.Dy())
+		pts := make([][2]float64, len(sl.Values))
+		for i, v := range sl.Values {
+			f := 0.5
+			if max > min {
+				f = tools.Fraction(v, min, max)
+			}
+			pts[i] = [2]float64{cx0 + cw*float64(i)/float64(len(sl.Values)-1), cy0 + ch*(1-f)}
+		}
+
+		cv := newCanvas(dst)
+		area := append([][2]float64{{cx0, cy0 + ch}}, pts...)
+		area = append(area, [2]float64{cx0 + cw, cy0 + ch})
+		cv.polygon(area)
+		r, g, bl, _ := s.Fill.RGBA()
+		cv.fill(color.NRGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(bl >> 8), A: 0x50})
+		cv.polyline(pts, math.Max(2, math.Min(w, h)*0.025), s.Fill)
+	}
+
+	err := s.text(dst, sl.Label, rect(b, 0.06, 0.04, 0.5, 0.3), h*0.2, render.AlignLeft, s.Foreground)
+	if err != nil {
+		return err
+	}
+	if len(sl.Values) == 0 {
+		return nil
+	}
+	last := sl.Values[len(sl.Values)-1]
+	return s.text(dst, formatValue(last, min, max)+sl.Units, rect(b, 0.5, 0.04, 0.94, 0.3), h*0.2, render.AlignRight, s.Foreground)
+}
+
+// History keeps the most recent values for a Sparkline. It is safe for
+// concurrent use. The zero value keeps nothing, use NewHistory.
+type History struct {
+	mu     sync.Mutex
+	values []float64
+	size   int
+}
+
+// NewHistory creates a History keeping up to size values, at least one.
+func NewHistory(size int) *History {
+	size = max(size, 1)
+	return &History{size: size, values: make([]float64, 0, size)}
+}
+
+// Push adds a value, dropping the oldest if the history is full.
+func (h *History) Push(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.size <= 0 {
+		return
+	}
+	if len(h.values) == h.size {
+		copy(h.values, h.values[1:])
+		h.values = h.values[:h.size-1]
+	}
+	h.values = append(h.values, v)
+}
+
+// Values returns a copy of the values, oldest first.
+func (h *History) Values() []float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]float64(nil), h.values...)
+}
+
+// Badge is a label with a notification style counter in the top right
+// corner. The counter is hidden when zero or less, and counts over 99 are shown as
+// 99+.
+type Badge struct {
+	Count int
+	Label string
+	Style Style
+}
+
+func (bg Badge) Draw(dst draw.Image) error {
+	s := bg.Style.withDefaults()
+	background(dst, s)
+	b := dst.Bounds()
+	w, h := float64(b.Dx()), float64(b.Dy())
+
+	err := s.text(dst, bg.Label, rect(b, 0.06, 0.3, 0.94, 0.9), h*0.22, render.AlignCenter, s.Foreground)
+	if err != nil {
+		return err
+	}
+	if bg.Count <= 0 {
+		return nil
+	}
+
+	r := math.Min(w, h) * 0.19
+	cx, cy := w-r*1.15, r*1.15
+	newCanvas(dst).circle(cx, cy, r, s.Alert)
+
+	count := strconv.Itoa(bg.Count)
+	if bg.Count > 99 {
+		count = "99+"
+	}
+	area := image.Rect(b.Min.X+int(cx-r*0.8), b.Min.Y+int(cy-r*0.8), b.Min.X+int(cx+r*0.8), b.Min.Y+int(cy+r*0.8))
+	return s.text(dst, count, area, r*1.1, render.AlignCenter, color.White)
+}
@@ -0,0 +1,121 @@
+package streamdeck
+
+import (
+	"image"
+	"sync"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+	"github.com/tardisx/streamdeck-plugin/layout"
+)
+
+// TouchHit is a tap on the touch strip matched to an item of the layout
+// shown by the tapped context.
+type TouchHit struct {
+	Event  events.ERTouchTap
+	Layout string
+	Item   layout.Item
+	// Position is where the tap was, relative to the top left of the item.
+	Position image.Point
+	Hold     bool
+}
+
+// TouchRouter matches taps on the touch strip to the items of the layout
+// each context is showing, and calls the handler registered for the item
+// key.
+//
+// The layout of a context is the one its FeedbackState reports, the last one
+// set with setFeedbackLayout since it appeared. Before that it is the layout
+// from the manifest, which must be given to SetDefaultLayout. Custom layouts
+// must be added with AddLayout.
+type TouchRouter struct {
+	feedback *FeedbackState
+
+	mu       sync.Mutex
+	layouts  map[string]*layout.Custom
+	defaults map[string]string // action to layout
+	handlers map[string]func(TouchHit)
+}
+
+// NewTouchRouter creates a TouchRouter, which finds the layout of each
+// context from feedback. If feedback is nil a FeedbackState is created for
+// it. It should be created before Connect, so that it sees every willAppear.
+func NewTouchRouter(conn *Connection, feedback *FeedbackState) *TouchRouter {
+	if feedback == nil {
+		feedback = NewFeedbackState(conn)
+	}
+	r := &TouchRouter{
+		feedback: feedback,
+		layouts:  make(map[string]*layout.Custom),
+		defaults: make(map[string]string),
+		handlers: make(map[string]func(TouchHit)),
+	}
+	conn.observe(func(event any) {
+		e, ok := event.(events.ERTouchTap)
+		if !ok {
+			return
+		}
+		hit, ok := r.HitTest(e)
+		if !ok {
+			return
+		}
+		r.mu.Lock()
+		fn := r.handlers[hit.Item.Key]
+		r.mu.Unlock()
+		if fn != nil {
+			fn(hit)
+		}
+	})
+	return r
+}
+
+// AddLayout makes a custom layout known, so that taps can be matched to its
+// items.
+func (r *TouchRouter) AddLayout(l *layout.Custom) {
+	r.mu.Lock()
+	r.layouts[l.Layout()] = l
+	r.mu.Unlock()
+}
+
+// SetDefaultLayout sets the layout an action has in the manifest, which is
+// used until another is set with setFeedbackLayout.
+func (r *TouchRouter) SetDefaultLayout(action string, name string) {
+	r.mu.Lock()
+	r.defaults[action] = name
+	r.mu.Unlock()
+}
+
+// HandleItem registers a function to be called when the item with the given
+// key is tapped, in any layout. It replaces any function already registered
+// for the key.
+func (r *TouchRouter) HandleItem(key string, fn func(TouchHit)) {
+	r.mu.Lock()
+	r.handlers[key] = fn
+	r.mu.Unlock()
+}
+
+// HitTest matches a tap to the item of the layout the context is showing.
+// It returns false if the layout is not known, or no item was tapped.
+func (r *TouchRouter) HitTest(e events.ERTouchTap) (TouchHit, bool) {
+	if len(e.Payload.TapPosition) < 2 {
+		return TouchHit{}, false
+	}
+	name := r.feedback.Layout(e.Context)
+	r.mu.Lock()
+	if name == "" {
+		name = r.defaults[e.Action]
+	}
+	l, ok := r.layouts[name]
+	r.mu.Unlock()
+	if !ok {
+		l, ok = layout.Builtin(name)
+	}
+	if !ok {
+		return TouchHit{}, false
+	}
+
+	it, pos, ok := l.HitTest(image.Pt(e.Payload.TapPosition[0], e.Payload.TapPosition[1]))
+	if !ok {
+		return TouchHit{}, false
+	}
+	return TouchHit{Event: e, Layout: name, Item: it, Position: pos, Hold: e.Payload.Hold}, true
+}
@@ -0,0 +1,61 @@
+package streamdeck
+
+import (
+	"image"
+	"testing"
+
+	"github.com/tardisx/streamdeck-plugin/events"
+	"github.com/tardisx/streamdeck-plugin/layout"
+)
+
+func tap(context string, x, y int) events.ERTouchTap {
+	e := events.ERTouchTap{ERCommon: events.ERCommon{Action: "com.example.dial", Context: context}}
+	e.Payload.TapPosition = []int{x, y}
+	return e
+}
+
+func TestTouchRouter(t *testing.T) {
+	pluginEnd, _ := NewPipeTransport()
+	c := NewWithTransport(pluginEnd)
+	fs := NewFeedbackState(&c)
+	r := NewTouchRouter(&c, fs)
+	r.SetDefaultLayout("com.example.dial", layout.LayoutB1)
+	custom := layout.NewCustom("com.example.buttons", "layouts/buttons.json").
+		Pixmap("left", image.Rect(0, 0, 100, 100), nil).
+		Pixmap("right", image.Rect(100, 0, 200, 100), nil)
+	r.AddLayout(custom)
+
+	hits := []TouchHit{}
+	for _, key := range []string{layout.KeyIndicator, "right"} {
+		r.HandleItem(key, func(h TouchHit) { hits = append(hits, h) })
+	}
+
+	// the default layout, then the custom one
+	c.handle(tap("ABC123", 80, 80))
+	c.handle(tap("ABC123", 30, 20)) // the title, which has no handler
+	fs.SetLayout("ABC123", custom.Layout())
+	c.handle(tap("ABC123", 150, 20))
+	c.handle(tap("ABC123", 5, 5))
+	// back to the default when it reappears
+	c.handle(events.ERWillAppear{ERCommon: events.ERCommon{Action: "com.example.dial", Context: "ABC123"}})
+	c.handle(tap("ABC123", 150, 20))
+
+	if len(hits) != 2 {
+		t.Fatalf("expected 2 hits, got %+v", hits)
+	}
+	if hits[0].Layout != layout.LayoutB1 || hits[0].Item.Key != layout.KeyIndicator || hits[0].Position != image.Pt(4, 6) {
+		t.Errorf("wrong first hit %+v", hits[0])
+	}
+	if hits[1].Layout != "layouts/buttons.json" || hits[1].Item.Key != "right" || hits[1].Position != image.Pt(50, 20) {
+		t.Errorf("wrong second hit %+v", hits[1])
+	}
+
+	// the title of $A0 is drawn over the full canvas
+	a0, _ := layout.Builtin(layout.LayoutA0)
+	if it, _, _ := a0.HitTest(image.Pt(20, 20)); it.Key != layout.KeyTitle {
+		t.Errorf("expected the title, got %s", it.Key)
+	}
+	if it, _, _ := a0.HitTest(image.Pt(20, 80)); it.Key != layout.KeyFullCanvas {
+		t.Errorf("expected the canvas, got %s", it.Key)
+	}
+}
@@ -0,0 +1,172 @@
+package streamdeck
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Transport carries frames between the plugin and the Stream Deck API. Each
+// frame is a single JSON message. ReadFrame is only ever called from one
+// goroutine, but WriteFrame may be called concurrently.
+type Transport interface {
+	ReadFrame() ([]byte, error)
+	WriteFrame([]byte) error
+	Close() error
+}
+
+// websocketTransport is the default transport, used when the plugin is
+// started by the Stream Deck application.
+type websocketTransport struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+}
+
+// NewWebsocketTransport wraps an established gorilla websocket connection.
+func NewWebsocketTransport(ws *websocket.Conn) Transport {
+	return &websocketTransport{ws: ws}
+}
+
+func (t *websocketTransport) ReadFrame() ([]byte, error) {
+	_, b, err := t.ws.ReadMessage()
+	return b, err
+}
+
+func (t *websocketTransport) WriteFrame(b []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	return t.ws.WriteMessage(websocket.TextMessage, b)
+}
+
+// Close sends a close message, so the other end shuts down cleanly, before
+// closing the connection.
+func (t *websocketTransport) Close() error {
+	t.writeMu.Lock()
+	t.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	t.writeMu.Unlock()
+	return t.ws.Close()
+}
+
+// pipeBuffer is the number of frames each direction of a pipe can hold
+// before writes block.
+const pipeBuffer = 64
+
+type pipeTransport struct {
+	in     <-chan []byte
+	out    chan<- []byte
+	closed chan bool
+	once   *sync.Once
+}
+
+// NewPipeTransport returns two connected in-memory transports. Frames
+// written to one are read from the other. Closing either end closes both,
+// after which reads return io.EOF once any buffered frames are consumed.
+// This is mostly useful for tests, with one end given to NewWithTransport
+// and the other playing the part of the Stream Deck application.
+func NewPipeTransport() (Transport, Transport) {
+	a := make(chan []byte, pipeBuffer)
+	b := make(chan []byte, pipeBuffer)
+	closed := make(chan bool)
+	once := &sync.Once{}
+	return &pipeTransport{in: a, out: b, closed: closed, once: once},
+		&pipeTransport{in: b, out: a, closed: closed, once: once}
+}
+
+func (t *pipeTransport) ReadFrame() ([]byte, error) {
+	// drain anything already buffered before honouring a close
+	select {
+	case b := <-t.in:
+		return b, nil
+	default:
+	}
+	select {
+	case b := <-t.in:
+		return b, nil
+	case <-t.closed:
+		return nil, io.EOF
+	}
+}
+
+func (t *pipeTransport) WriteFrame(b []byte) error {
+	// the caller may reuse b
+	frame := bytes.Clone(b)
+	select {
+	case <-t.closed:
+		return io.ErrClosedPipe
+	default:
+	}
+	select {
+	case t.out <- frame:
+		return nil
+	case <-t.closed:
+		return io.ErrClosedPipe
+	}
+}
+
+func (t *pipeTransport) Close() error {
+	t.once.Do(func() { close(t.closed) })
+	return nil
+}
+
+type stdioTransport struct {
+	r       *bufio.Reader
+	w       io.Writer
+	closers []io.Closer
+	writeMu sync.Mutex
+}
+
+// NewStdioTransport returns a transport exchanging newline delimited JSON
+// frames over a reader and writer, typically os.Stdin and os.Stdout when a
+// plugin is run under a test harness rather than by the Stream Deck
+// application. Close closes r and w if they implement io.Closer.
+func NewStdioTransport(r io.Reader, w io.Writer) Transport {
+	t := &stdioTransport{
+		r: bufio.NewReader(r),
+		w: w,
+	}
+	if c, ok := r.(io.Closer); ok {
+		t.closers = append(t.closers, c)
+	}
+	if c, ok := w.(io.Closer); ok {
+		t.closers = append(t.closers, c)
+	}
+	return t
+}
+
+func (t *stdioTransport) ReadFrame() ([]byte, error) {
+	for {
+		b, err := t.r.ReadBytes('\n')
+		b = bytes.TrimSpace(b)
+		if len(b) > 0 {
+			// a final frame without a trailing newline is still a frame
+			return b, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *stdioTransport) WriteFrame(b []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	frame := make([]byte, 0, len(b)+1)
+	frame = append(frame, b...)
+	frame = append(frame, '\n')
+	_, err := t.w.Write(frame)
+	return err
+}
+
+func (t *stdioTransport) Close() error {
+	var first error
+	for _, c := range t.closers {
+		err := c.Close()
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,35 @@
+package streamdeck
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStdioTransport(t *testing.T) {
+	in := strings.NewReader("{\"event\":\"keyDown\"}\n\n{\"event\":\"keyUp\"}")
+	out := bytes.Buffer{}
+	tr := NewStdioTransport(in, &out)
+
+	for _, expected := range []string{`{"event":"keyDown"}`, `{"event":"keyUp"}`} {
+		b, err := tr.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != expected {
+			t.Errorf("expected %s, got %s", expected, b)
+		}
+	}
+	_, err := tr.ReadFrame()
+	if err == nil {
+		t.Error("expected an error at the end of input")
+	}
+
+	err = tr.WriteFrame([]byte(`{"event":"setTitle"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "{\"event\":\"setTitle\"}\n" {
+		t.Errorf("wrong output %q", out.String())
+	}
+}